@@ -6,21 +6,18 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"products-api/internal/broker"
+	"products-api/internal/config"
 	"products-api/internal/database"
 	"products-api/internal/handlers"
 	"products-api/internal/repository"
 	"products-api/internal/routes"
 	"products-api/internal/server"
 	"products-api/internal/services"
-	"strconv"
 	"syscall"
-	"time"
-
-	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
-	_ "github.com/joho/godotenv/autoload"
 )
 
-func gracefulShutdown(fiberServer *server.FiberServer, done chan bool) {
+func gracefulShutdown(fiberServer *server.FiberServer, cfg *config.Config, done chan bool) {
 	// Create context that listens for the interrupt signal from the OS.
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
@@ -31,12 +28,16 @@ func gracefulShutdown(fiberServer *server.FiberServer, done chan bool) {
 	log.Println("shutting down gracefully, press Ctrl+C again to force")
 	stop() // Allow Ctrl+C to force shutdown
 
-	// Stop message processors first
-	fiberServer.StopMessageProcessors()
+	// Stop message processors first, giving in-flight messages time to finish
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.Messaging.DrainTimeout)
+	defer cancelDrain()
+	if err := fiberServer.StopMessageProcessors(drainCtx); err != nil {
+		log.Printf("Message processors did not drain in time: %v", err)
+	}
 
-	// The context is used to inform the server it has 5 seconds to finish
+	// The context is used to inform the server how long it has to finish
 	// the request it is currently handling
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 	if err := fiberServer.ShutdownWithContext(ctx); err != nil {
 		log.Printf("Server forced to shutdown with error: %v", err)
@@ -49,37 +50,132 @@ func gracefulShutdown(fiberServer *server.FiberServer, done chan bool) {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// What to do with events no handler is registered for; validated by config.Load
+	fallback, _ := server.ParseFallbackPolicy(cfg.Messaging.Fallback)
 
-	server := server.New()
+	// Handlers the messaging config can name, filled in once the server exists
+	messageHandlers := map[string]server.MessageHandler{}
+
+	db, err := database.New(cfg.Database)
+	if err != nil {
+		log.Fatalf("connecting to database: %v", err)
+	}
+	defer db.Close()
+
+	server, err := server.New(cfg, db)
+	if err != nil {
+		log.Fatalf("creating server: %v", err)
+	}
 
 	server.RegisterFiberRoutes()
-	dbInstance := database.New().GetDB()
+	dbInstance := db.GetDB()
+	transactor := repository.NewTransactor(dbInstance)
 	productRepo := repository.NewProductRepository(dbInstance)
-	prodcutService := services.NewProductService(productRepo)
-	productHandler := handlers.NewProductHandler(prodcutService)
+	inboxRepo := repository.NewInboxRepository(dbInstance)
+	outboxRepo := repository.NewOutboxRepository(dbInstance)
+	reservationRepo := repository.NewReservationRepository(dbInstance)
+	prodcutService := services.NewProductService(productRepo, reservationRepo, inboxRepo, outboxRepo, transactor)
+	server.SetProductService(prodcutService)
+	pricingService := services.NewPricingService(repository.NewPriceRepository(dbInstance), repository.NewExchangeRateRepository(dbInstance))
+	productHandler := handlers.NewProductHandler(prodcutService, pricingService)
 	productRoutes := routes.NewProductRoutes(*productHandler)
 	productRoutes.RegisterRoutes(server)
-	// Add message processors for your queues
-	server.AddMessageProcessor("http://localstack:4566/000000000000/OrderCreatedTopic", func(msg *types.Message) error {
-		return server.HandleProductMessage(msg)
+
+	priceHandler := handlers.NewPriceHandler(pricingService)
+	priceRoutes := routes.NewPriceRoutes(*priceHandler)
+	priceRoutes.RegisterRoutes(server)
+
+	reservationService := services.NewReservationService(transactor, productRepo, reservationRepo, outboxRepo, cfg.Jobs.ReservationTTL, cfg.Jobs.ReservationMaxTTL)
+	reservationHandler := handlers.NewReservationHandler(reservationService)
+	reservationRoutes := routes.NewReservationRoutes(*reservationHandler)
+	reservationRoutes.RegisterRoutes(server)
+
+	orderRepo := repository.NewOrderRepository(dbInstance)
+	orderService := services.NewOrderService(transactor, productRepo, orderRepo, outboxRepo)
+	orderHandler := handlers.NewOrderHandler(orderService)
+	orderRoutes := routes.NewOrderRoutes(*orderHandler)
+	orderRoutes.RegisterRoutes(server)
+
+	// Consume the queues and resolve the topics declared in the messaging config
+	var topics map[string]string
+	if cfg.Features.MessageProcessing || cfg.Features.OutboxRelay {
+		server.RegisterMessageHandlers(fallback)
+		messagingConfig, err := broker.LoadConfig(cfg.Messaging.ConfigPath)
+		if err != nil {
+			log.Fatalf("loading messaging config: %v", err)
+		}
+		if !cfg.Features.MessageProcessing {
+			messagingConfig.Subscriptions = nil
+		}
+		messageHandlers["router"] = server.RouteMessage
+		topics, err = server.ConfigureMessaging(context.Background(), messagingConfig, messageHandlers)
+		if err != nil {
+			log.Fatalf("configuring messaging: %v", err)
+		}
+	}
+
+	// Hard-delete products that have been soft deleted for longer than the retention period
+	purgeRetention := cfg.Jobs.ProductPurgeRetention
+	server.AddJob("product-purge", cfg.Jobs.ProductPurgeInterval, func(ctx context.Context) error {
+		_, err := prodcutService.PurgeDeletedProducts(ctx, purgeRetention)
+		return err
 	})
 
-	// Start background message processors
+	// Return stock held by abandoned checkouts
+	server.AddJob("reservation-sweeper", cfg.Jobs.ReservationSweepInterval, func(ctx context.Context) error {
+		_, err := reservationService.ExpireReservations(ctx)
+		return err
+	})
+
+	// Forget processed messages once the queue can no longer redeliver them
+	inboxRetention := cfg.Jobs.InboxRetention
+	server.AddJob("inbox-cleanup", cfg.Jobs.InboxCleanupInterval, func(ctx context.Context) error {
+		_, err := prodcutService.PurgeProcessedMessages(ctx, inboxRetention)
+		return err
+	})
+
+	// Publish product domain events recorded in the outbox
+	if topicArn := topics["product-events"]; cfg.Features.OutboxRelay && topicArn != "" {
+		outboxRelay := services.NewOutboxRelay(transactor, outboxRepo, server.OutboxPublisher(topicArn))
+		server.AddJob("outbox-relay", cfg.Jobs.OutboxRelayInterval, func(ctx context.Context) error {
+			_, err := outboxRelay.RelayPending(ctx)
+			return err
+		})
+		outboxRetention := cfg.Jobs.OutboxRetention
+		server.AddJob("outbox-cleanup", cfg.Jobs.OutboxCleanupInterval, func(ctx context.Context) error {
+			_, err := outboxRelay.PurgeSent(ctx, outboxRetention)
+			return err
+		})
+	} else {
+		log.Println("Outbox relay disabled or no product-events topic configured; product events will accumulate in the outbox unpublished")
+	}
+
+	// Start background message processors and jobs
 	server.StartMessageProcessors()
+	server.StartJobs()
 
 	// Create a done channel to signal when the shutdown is complete
 	done := make(chan bool, 1)
 
 	go func() {
-		port, _ := strconv.Atoi(os.Getenv("PORT"))
-		err := server.Listen(fmt.Sprintf(":%d", port))
+		err := server.Listen(fmt.Sprintf(":%d", cfg.Server.Port))
 		if err != nil {
 			panic(fmt.Sprintf("http server error: %s", err))
 		}
 	}()
 
 	// Run graceful shutdown in a separate goroutine
-	go gracefulShutdown(server, done)
+	go gracefulShutdown(server, cfg, done)
 
 	// Wait for the graceful shutdown to complete
 	<-done
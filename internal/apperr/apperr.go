@@ -0,0 +1,73 @@
+// Package apperr defines the errors the application reports to clients. Each
+// carries a Kind, which decides the HTTP status, a stable machine-readable
+// Code and a Message that is safe to show. The underlying cause, if any, is
+// kept for logs and errors.Is but never sent to clients.
+package apperr
+
+import "errors"
+
+// Kind classifies an error by what the client can do about it
+type Kind int
+
+const (
+	// Internal errors are the server's fault; their details are never shown
+	Internal Kind = iota
+	BadRequest
+	NotFound
+	Conflict
+	InsufficientStock
+	UnsupportedMediaType
+	// Unavailable means a dependency the request needs is not configured or down
+	Unavailable
+	// BadGateway means a dependency failed while handling the request
+	BadGateway
+	// PreconditionRequired means a write must be made conditional on the
+	// version the client read
+	PreconditionRequired
+	// PreconditionFailed means the resource changed since the client read it
+	PreconditionFailed
+)
+
+// Error is an error with a client-safe description
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Err     error
+}
+
+// New returns an error of kind with a stable code and a client-safe message
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// Wrap is New with an underlying cause, which is logged but not shown to clients
+func Wrap(kind Kind, code, message string, err error) *Error {
+	return &Error{Kind: kind, Code: code, Message: message, Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// As returns the first *Error in err's chain
+func As(err error) (*Error, bool) {
+	var appErr *Error
+	ok := errors.As(err, &appErr)
+	return appErr, ok
+}
+
+// ErrInvalidBody is returned when a request body cannot be decoded
+var ErrInvalidBody = New(BadRequest, "invalid_body", "request body is invalid")
+
+// InvalidParameter reports an invalid query or path parameter
+func InvalidParameter(message string) *Error {
+	return New(BadRequest, "invalid_parameter", message)
+}
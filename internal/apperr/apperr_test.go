@@ -0,0 +1,24 @@
+package apperr
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorKeepsCauseForErrorsIs(t *testing.T) {
+	notFound := Wrap(NotFound, "product_not_found", "product not found", sql.ErrNoRows)
+	err := fmt.Errorf("product p1: %w", notFound)
+
+	if !errors.Is(err, notFound) || !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected %v to match the sentinel and its cause", err)
+	}
+	appErr, ok := As(err)
+	if !ok || appErr.Code != "product_not_found" || appErr.Message != "product not found" {
+		t.Fatalf("unexpected error: %+v", appErr)
+	}
+	if _, ok := As(errors.New("plain")); ok {
+		t.Error("expected plain errors not to be application errors")
+	}
+}
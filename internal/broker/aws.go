@@ -0,0 +1,316 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// AWS is a Broker backed by SNS topics and SQS queues. Topics are topic ARNs
+// and queues are queue URLs.
+type AWS struct {
+	sns *sns.Client
+	sqs *sqs.Client
+}
+
+// NewAWS creates a broker from SNS and SQS clients
+func NewAWS(snsClient *sns.Client, sqsClient *sqs.Client) *AWS {
+	return &AWS{sns: snsClient, sqs: sqsClient}
+}
+
+// Publish publishes msg to an SNS topic
+func (b *AWS) Publish(ctx context.Context, topic string, msg OutgoingMessage) (string, error) {
+	input := &sns.PublishInput{
+		TopicArn: &topic,
+		Message:  &msg.Body,
+	}
+	if len(msg.Attributes) > 0 {
+		input.MessageAttributes = make(map[string]snstypes.MessageAttributeValue, len(msg.Attributes))
+		for name, value := range msg.Attributes {
+			input.MessageAttributes[name] = snstypes.MessageAttributeValue{DataType: stringPtr("String"), StringValue: stringPtr(value)}
+		}
+	}
+	if msg.GroupID != "" {
+		input.MessageGroupId = &msg.GroupID
+	}
+	if msg.DeduplicationID != "" {
+		input.MessageDeduplicationId = &msg.DeduplicationID
+	}
+	result, err := b.sns.Publish(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return deref(result.MessageId), nil
+}
+
+// Receive receives messages from an SQS queue. SQS cannot receive without
+// hiding, so a peek makes the messages visible again straight away.
+func (b *AWS) Receive(ctx context.Context, queue string, opts ReceiveOptions) ([]Message, error) {
+	result, err := b.sqs.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:              &queue,
+		MaxNumberOfMessages:   int32(opts.MaxMessages),
+		WaitTimeSeconds:       int32(opts.WaitTime / time.Second),
+		VisibilityTimeout:     int32(opts.VisibilityTimeout / time.Second),
+		MessageAttributeNames: []string{"All"},
+		MessageSystemAttributeNames: []types.MessageSystemAttributeName{
+			types.MessageSystemAttributeNameApproximateReceiveCount,
+			types.MessageSystemAttributeNameSentTimestamp,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]Message, 0, len(result.Messages))
+	for _, msg := range result.Messages {
+		received := Message{
+			ID:           deref(msg.MessageId),
+			Body:         deref(msg.Body),
+			Attributes:   make(map[string]string, len(msg.MessageAttributes)),
+			ReceiveCount: 1,
+			Receipt:      deref(msg.ReceiptHandle),
+		}
+		for name, value := range msg.MessageAttributes {
+			if value.StringValue != nil {
+				received.Attributes[name] = *value.StringValue
+			}
+		}
+		if count, err := strconv.Atoi(msg.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)]); err == nil {
+			received.ReceiveCount = count
+		}
+		if sent, err := strconv.ParseInt(msg.Attributes[string(types.MessageSystemAttributeNameSentTimestamp)], 10, 64); err == nil {
+			received.SentAt = time.UnixMilli(sent)
+		}
+		messages = append(messages, received)
+	}
+	if opts.Peek {
+		if err := b.makeVisible(ctx, queue, messages); err != nil {
+			return nil, err
+		}
+	}
+	return messages, nil
+}
+
+// makeVisible resets the visibility timeout of received messages to zero.
+// ChangeMessageVisibilityBatch always sends the timeout, unlike ReceiveMessage.
+func (b *AWS) makeVisible(ctx context.Context, queue string, messages []Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	entries := make([]types.ChangeMessageVisibilityBatchRequestEntry, len(messages))
+	for i := range messages {
+		entries[i] = types.ChangeMessageVisibilityBatchRequestEntry{
+			Id:            stringPtr(strconv.Itoa(i)),
+			ReceiptHandle: &messages[i].Receipt,
+		}
+	}
+	result, err := b.sqs.ChangeMessageVisibilityBatch(ctx, &sqs.ChangeMessageVisibilityBatchInput{
+		QueueUrl: &queue,
+		Entries:  entries,
+	})
+	if err != nil {
+		return err
+	}
+	if len(result.Failed) > 0 {
+		return fmt.Errorf("sqs: %d of %d peeked messages stay hidden: %s", len(result.Failed), len(messages), deref(result.Failed[0].Code))
+	}
+	return nil
+}
+
+// Delete deletes messages from an SQS queue with DeleteMessageBatch
+func (b *AWS) Delete(ctx context.Context, queue string, receipts ...string) ([]DeleteFailure, error) {
+	if len(receipts) == 0 {
+		return nil, nil
+	}
+	entries := make([]types.DeleteMessageBatchRequestEntry, len(receipts))
+	for i := range receipts {
+		entries[i] = types.DeleteMessageBatchRequestEntry{
+			Id:            stringPtr(strconv.Itoa(i)),
+			ReceiptHandle: &receipts[i],
+		}
+	}
+	result, err := b.sqs.DeleteMessageBatch(ctx, &sqs.DeleteMessageBatchInput{
+		QueueUrl: &queue,
+		Entries:  entries,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var failures []DeleteFailure
+	for _, failed := range result.Failed {
+		i, err := strconv.Atoi(deref(failed.Id))
+		if err != nil || i < 0 || i >= len(receipts) {
+			return nil, errors.New("sqs: unexpected entry id in DeleteMessageBatch response")
+		}
+		failures = append(failures, DeleteFailure{
+			Receipt:   receipts[i],
+			Code:      deref(failed.Code),
+			Retryable: !failed.SenderFault,
+		})
+	}
+	return failures, nil
+}
+
+// ChangeVisibility changes the visibility timeout of a received SQS message
+func (b *AWS) ChangeVisibility(ctx context.Context, queue, receipt string, timeout time.Duration) error {
+	_, err := b.sqs.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          &queue,
+		ReceiptHandle:     &receipt,
+		VisibilityTimeout: int32(timeout / time.Second),
+	})
+	return err
+}
+
+// Send sends msg to an SQS queue
+func (b *AWS) Send(ctx context.Context, queue string, msg OutgoingMessage) (string, error) {
+	input := &sqs.SendMessageInput{
+		QueueUrl:    &queue,
+		MessageBody: &msg.Body,
+	}
+	if len(msg.Attributes) > 0 {
+		input.MessageAttributes = make(map[string]types.MessageAttributeValue, len(msg.Attributes))
+		for name, value := range msg.Attributes {
+			input.MessageAttributes[name] = types.MessageAttributeValue{DataType: stringPtr("String"), StringValue: stringPtr(value)}
+		}
+	}
+	if msg.GroupID != "" {
+		input.MessageGroupId = &msg.GroupID
+	}
+	if msg.DeduplicationID != "" {
+		input.MessageDeduplicationId = &msg.DeduplicationID
+	}
+	result, err := b.sqs.SendMessage(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return deref(result.MessageId), nil
+}
+
+// Depth returns the ApproximateNumberOfMessages attribute of an SQS queue
+func (b *AWS) Depth(ctx context.Context, queue string) (int, error) {
+	result, err := b.sqs.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       &queue,
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameApproximateNumberOfMessages},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(result.Attributes[string(types.QueueAttributeNameApproximateNumberOfMessages)])
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// QueueURL looks up an SQS queue URL by queue name
+func (b *AWS) QueueURL(ctx context.Context, name string) (string, error) {
+	result, err := b.sqs.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: &name})
+	if err != nil {
+		return "", err
+	}
+	return deref(result.QueueUrl), nil
+}
+
+// TopicARN looks up an SNS topic ARN by topic name. SNS has no lookup by
+// name, so the account's topics are listed until one matches.
+func (b *AWS) TopicARN(ctx context.Context, name string) (string, error) {
+	paginator := sns.NewListTopicsPaginator(b.sns, &sns.ListTopicsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return "", err
+		}
+		for _, topic := range page.Topics {
+			if arn := deref(topic.TopicArn); strings.HasSuffix(arn, ":"+name) {
+				return arn, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("topic %s not found", name)
+}
+
+// CreateQueue creates an SQS queue, or returns the URL of an existing one.
+// Names ending in .fifo create FIFO queues.
+func (b *AWS) CreateQueue(ctx context.Context, name string) (string, error) {
+	input := &sqs.CreateQueueInput{QueueName: &name}
+	if strings.HasSuffix(name, ".fifo") {
+		input.Attributes = map[string]string{string(types.QueueAttributeNameFifoQueue): "true"}
+	}
+	result, err := b.sqs.CreateQueue(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return deref(result.QueueUrl), nil
+}
+
+// CreateTopic creates an SNS topic, or returns the ARN of an existing one.
+// Names ending in .fifo create FIFO topics.
+func (b *AWS) CreateTopic(ctx context.Context, name string) (string, error) {
+	input := &sns.CreateTopicInput{Name: &name}
+	if strings.HasSuffix(name, ".fifo") {
+		input.Attributes = map[string]string{"FifoTopic": "true"}
+	}
+	result, err := b.sns.CreateTopic(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return deref(result.TopicArn), nil
+}
+
+// Subscribe subscribes an SQS queue to an SNS topic and allows the topic to
+// send to the queue
+func (b *AWS) Subscribe(ctx context.Context, topic, queue string, raw bool) error {
+	attrs, err := b.sqs.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       &queue,
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameQueueArn},
+	})
+	if err != nil {
+		return err
+	}
+	queueArn := attrs.Attributes[string(types.QueueAttributeNameQueueArn)]
+
+	policy, err := json.Marshal(map[string]any{
+		"Version": "2012-10-17",
+		"Statement": []map[string]any{{
+			"Effect":    "Allow",
+			"Principal": map[string]string{"Service": "sns.amazonaws.com"},
+			"Action":    "sqs:SendMessage",
+			"Resource":  queueArn,
+			"Condition": map[string]any{"ArnEquals": map[string]string{"aws:SourceArn": topic}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := b.sqs.SetQueueAttributes(ctx, &sqs.SetQueueAttributesInput{
+		QueueUrl:   &queue,
+		Attributes: map[string]string{string(types.QueueAttributeNamePolicy): string(policy)},
+	}); err != nil {
+		return err
+	}
+
+	_, err = b.sns.Subscribe(ctx, &sns.SubscribeInput{
+		TopicArn:   &topic,
+		Protocol:   stringPtr("sqs"),
+		Endpoint:   &queueArn,
+		Attributes: map[string]string{"RawMessageDelivery": strconv.FormatBool(raw)},
+	})
+	return err
+}
@@ -0,0 +1,81 @@
+// Package broker abstracts the message broker behind publishing events to
+// topics and consuming them from queues, so the server can run against AWS
+// SNS/SQS or against an in-memory broker in tests and local development.
+package broker
+
+import (
+	"context"
+	"time"
+)
+
+// Message is a message received from a queue
+type Message struct {
+	ID   string
+	Body string
+	// Attributes are the message attributes set by the sender
+	Attributes map[string]string
+	// ReceiveCount is how many times the message has been received, including this time
+	ReceiveCount int
+	SentAt       time.Time
+	// Receipt identifies this delivery when deleting the message or changing its visibility
+	Receipt string
+}
+
+// OutgoingMessage is a message to publish to a topic or send to a queue
+type OutgoingMessage struct {
+	Body       string
+	Attributes map[string]string
+	// GroupID and DeduplicationID are only used by FIFO topics and queues
+	GroupID         string
+	DeduplicationID string
+}
+
+// ReceiveOptions tune a single Receive call
+type ReceiveOptions struct {
+	// MaxMessages is how many messages to return at most (1-10)
+	MaxMessages int
+	// WaitTime is how long to wait for a message when the queue is empty
+	WaitTime time.Duration
+	// VisibilityTimeout hides received messages from other receivers. Zero
+	// means the queue's default, as SQS drops a zero timeout from the request.
+	VisibilityTimeout time.Duration
+	// Peek leaves received messages visible to other receivers
+	Peek bool
+}
+
+// DeleteFailure reports a receipt that could not be deleted
+type DeleteFailure struct {
+	Receipt string
+	Code    string
+	// Retryable is false when the receipt itself was rejected, for example
+	// because it expired; deleting it again cannot succeed
+	Retryable bool
+}
+
+// Publisher publishes messages to topics
+type Publisher interface {
+	// Publish sends msg to every subscriber of topic and returns the message ID
+	Publish(ctx context.Context, topic string, msg OutgoingMessage) (string, error)
+}
+
+// Consumer receives and settles messages on queues
+type Consumer interface {
+	Receive(ctx context.Context, queue string, opts ReceiveOptions) ([]Message, error)
+	// Delete acknowledges up to 10 received messages. Receipts that could not
+	// be deleted are reported as failures rather than as an error.
+	Delete(ctx context.Context, queue string, receipts ...string) ([]DeleteFailure, error)
+	// ChangeVisibility hides a received message for timeout from now
+	ChangeVisibility(ctx context.Context, queue, receipt string, timeout time.Duration) error
+	// Send puts msg directly on a queue and returns the message ID
+	Send(ctx context.Context, queue string, msg OutgoingMessage) (string, error)
+	// Depth returns the approximate number of messages waiting on a queue
+	Depth(ctx context.Context, queue string) (int, error)
+}
+
+// Broker publishes, consumes, and resolves and provisions queues and topics
+type Broker interface {
+	Publisher
+	Consumer
+	Resolver
+	Provisioner
+}
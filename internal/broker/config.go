@@ -0,0 +1,128 @@
+package broker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config declares the topics this service publishes to and the queues it
+// consumes. Queues and topics are referred to by name and resolved to URLs
+// and ARNs at startup. Topics are keyed by the role the application uses
+// them for, such as "product-events".
+type Config struct {
+	// Provision creates missing queues, topics and subscriptions at startup.
+	// It is meant for local development against LocalStack or the memory broker.
+	Provision     bool                 `yaml:"provision" json:"provision"`
+	Topics        map[string]TopicSpec `yaml:"topics" json:"topics"`
+	Subscriptions []SubscriptionSpec   `yaml:"subscriptions" json:"subscriptions"`
+}
+
+// TopicSpec declares a topic. ARN may be left empty to look the topic up by name.
+type TopicSpec struct {
+	Name string `yaml:"name" json:"name"`
+	ARN  string `yaml:"arn" json:"arn"`
+}
+
+// SubscriptionSpec declares a queue, the handler that consumes it and,
+// optionally, the topic that feeds it
+type SubscriptionSpec struct {
+	Queue   string `yaml:"queue" json:"queue"`
+	Handler string `yaml:"handler" json:"handler"`
+	// Topic names the topic that feeds the queue. It is only used to create
+	// the topic and the subscription when provisioning.
+	Topic           string `yaml:"topic" json:"topic"`
+	RawDelivery     bool   `yaml:"raw_delivery" json:"raw_delivery"`
+	DeadLetterQueue string `yaml:"dead_letter_queue" json:"dead_letter_queue"`
+
+	Workers           int      `yaml:"workers" json:"workers"`
+	MaxMessages       int      `yaml:"max_messages" json:"max_messages"`
+	WaitTime          Duration `yaml:"wait_time" json:"wait_time"`
+	VisibilityTimeout Duration `yaml:"visibility_timeout" json:"visibility_timeout"`
+	MaxReceiveCount   int      `yaml:"max_receive_count" json:"max_receive_count"`
+	BackoffBase       Duration `yaml:"backoff_base" json:"backoff_base"`
+	BackoffMax        Duration `yaml:"backoff_max" json:"backoff_max"`
+}
+
+// Duration is a time.Duration written as a string such as "30s" in config files
+type Duration time.Duration
+
+// UnmarshalText parses a duration string
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// LoadConfig reads a YAML or JSON config file, chosen by extension. ${VAR}
+// and ${VAR:-default} references are replaced from the environment before
+// parsing, so deployments can override individual values.
+func LoadConfig(path string) (*Config, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	expanded := []byte(expandEnv(string(raw)))
+
+	var cfg Config
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(expanded, &cfg)
+	case ".json":
+		err = json.Unmarshal(expanded, &cfg)
+	default:
+		return nil, fmt.Errorf("messaging config %s: unsupported format, use .yaml, .yml or .json", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("messaging config %s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("messaging config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// expandEnv replaces ${VAR} and ${VAR:-default} with environment values
+func expandEnv(s string) string {
+	return os.Expand(s, func(ref string) string {
+		name, def, hasDefault := strings.Cut(ref, ":-")
+		if value, ok := os.LookupEnv(name); ok && value != "" {
+			return value
+		}
+		if hasDefault {
+			return def
+		}
+		return ""
+	})
+}
+
+// Validate checks that every topic and subscription is named and that every
+// subscription has a handler
+func (c *Config) Validate() error {
+	var problems []string
+	for key, topic := range c.Topics {
+		if topic.Name == "" && topic.ARN == "" {
+			problems = append(problems, fmt.Sprintf("topic %q needs a name or an arn", key))
+		}
+	}
+	for i, sub := range c.Subscriptions {
+		if sub.Queue == "" {
+			problems = append(problems, fmt.Sprintf("subscription %d has no queue", i))
+		}
+		if sub.Handler == "" {
+			problems = append(problems, fmt.Sprintf("subscription %d has no handler", i))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid messaging config: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package broker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigYAML(t *testing.T) {
+	t.Setenv("ORDERS_QUEUE", "orders-prod")
+	path := writeConfig(t, "messaging.yaml", `
+provision: ${PROVISION:-true}
+topics:
+  product-events:
+    name: ProductEvents
+subscriptions:
+  - queue: ${ORDERS_QUEUE:-orders}
+    handler: router
+    dead_letter_queue: orders-dlq
+    workers: 4
+    visibility_timeout: 45s
+`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.Provision || cfg.Topics["product-events"].Name != "ProductEvents" || len(cfg.Subscriptions) != 1 {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	sub := cfg.Subscriptions[0]
+	if sub.Queue != "orders-prod" || sub.Workers != 4 || time.Duration(sub.VisibilityTimeout) != 45*time.Second {
+		t.Errorf("unexpected subscription: %+v", sub)
+	}
+}
+
+func TestLoadConfigJSON(t *testing.T) {
+	path := writeConfig(t, "messaging.json", `{"subscriptions":[{"queue":"orders","handler":"router","wait_time":"5s"}]}`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if time.Duration(cfg.Subscriptions[0].WaitTime) != 5*time.Second {
+		t.Errorf("unexpected subscription: %+v", cfg.Subscriptions[0])
+	}
+}
+
+func TestLoadConfigRejectsInvalidConfigs(t *testing.T) {
+	tests := map[string]struct {
+		name, content, want string
+	}{
+		"unknown format":   {"messaging.toml", ``, "unsupported format"},
+		"bad duration":     {"messaging.yaml", "subscriptions:\n  - {queue: q, handler: h, wait_time: soon}", "soon"},
+		"missing handler":  {"messaging.yaml", "subscriptions:\n  - {queue: q}", "has no handler"},
+		"unnamed topic":    {"messaging.json", `{"topics":{"events":{}}}`, "needs a name or an arn"},
+		"reports them all": {"messaging.yaml", "subscriptions:\n  - {}", "has no queue; subscription 0 has no handler"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadConfig(writeConfig(t, tt.name, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected an error containing %q; got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestResolveProvisionsSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemory()
+	cfg := &Config{
+		Provision: true,
+		Topics:    map[string]TopicSpec{"product-events": {Name: "ProductEvents"}, "fixed": {ARN: "arn:fixed"}},
+		Subscriptions: []SubscriptionSpec{
+			{Queue: "orders", Topic: "OrderCreated", Handler: "router", DeadLetterQueue: "orders-dlq", RawDelivery: true},
+		},
+	}
+
+	resolved, err := Resolve(ctx, b, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resolved.Topics["product-events"] != "ProductEvents" || resolved.Topics["fixed"] != "arn:fixed" {
+		t.Errorf("unexpected topics: %v", resolved.Topics)
+	}
+	sub := resolved.Subscriptions[0]
+	if sub.QueueURL != "orders" || sub.DLQURL != "orders-dlq" || sub.Handler != "router" {
+		t.Errorf("unexpected subscription: %+v", sub)
+	}
+
+	b.Publish(ctx, "OrderCreated", OutgoingMessage{Body: "order"})
+	if received, _ := b.Receive(ctx, "orders", ReceiveOptions{MaxMessages: 1}); len(received) != 1 || received[0].Body != "order" {
+		t.Errorf("expected the provisioned subscription to deliver; got %v", received)
+	}
+}
@@ -0,0 +1,259 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memoryPollInterval is how often a waiting Receive looks for messages that
+// were sent or became visible again
+const memoryPollInterval = 10 * time.Millisecond
+
+// DefaultVisibilityTimeout hides messages received without a visibility
+// timeout, like the default of an SQS queue
+const DefaultVisibilityTimeout = 30 * time.Second
+
+// Memory is an in-process Broker for tests and local development. Queues are
+// created on first use. Topics deliver to the queues subscribed to them,
+// wrapped in an SNS-style notification unless the subscription uses raw delivery.
+type Memory struct {
+	mu            sync.Mutex
+	queues        map[string][]*memoryMessage
+	subscriptions map[string][]memorySubscription
+	now           func() time.Time
+}
+
+type memoryMessage struct {
+	Message
+	visibleAt time.Time
+}
+
+type memorySubscription struct {
+	queue string
+	raw   bool
+}
+
+// NewMemory creates an empty in-memory broker
+func NewMemory() *Memory {
+	return &Memory{
+		queues:        make(map[string][]*memoryMessage),
+		subscriptions: make(map[string][]memorySubscription),
+		now:           time.Now,
+	}
+}
+
+// QueueURL returns name; memory queues are identified by their names
+func (b *Memory) QueueURL(ctx context.Context, name string) (string, error) {
+	return name, nil
+}
+
+// TopicARN returns name; memory topics are identified by their names
+func (b *Memory) TopicARN(ctx context.Context, name string) (string, error) {
+	return name, nil
+}
+
+// CreateQueue returns name; memory queues exist from their first use
+func (b *Memory) CreateQueue(ctx context.Context, name string) (string, error) {
+	return name, nil
+}
+
+// CreateTopic returns name; memory topics exist from their first subscription
+func (b *Memory) CreateTopic(ctx context.Context, name string) (string, error) {
+	return name, nil
+}
+
+// Subscribe delivers messages published to topic to queue. With raw delivery
+// the queue receives the published body and attributes as they are; otherwise
+// it receives an SNS notification envelope, as SQS does from a real SNS topic.
+// Subscribing the same queue twice has no effect.
+func (b *Memory) Subscribe(ctx context.Context, topic, queue string, raw bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, sub := range b.subscriptions[topic] {
+		if sub.queue == queue {
+			return nil
+		}
+	}
+	b.subscriptions[topic] = append(b.subscriptions[topic], memorySubscription{queue: queue, raw: raw})
+	return nil
+}
+
+// Publish delivers msg to every queue subscribed to topic
+func (b *Memory) Publish(ctx context.Context, topic string, msg OutgoingMessage) (string, error) {
+	id := uuid.NewString()
+
+	b.mu.Lock()
+	subscriptions := b.subscriptions[topic]
+	b.mu.Unlock()
+
+	for _, sub := range subscriptions {
+		delivered := msg
+		if !sub.raw {
+			body, err := snsNotification(id, topic, msg, b.now())
+			if err != nil {
+				return "", err
+			}
+			delivered = OutgoingMessage{Body: body}
+		}
+		if _, err := b.Send(ctx, sub.queue, delivered); err != nil {
+			return "", err
+		}
+	}
+	return id, nil
+}
+
+// snsNotification builds the body SNS sends to an SQS subscription without raw delivery
+func snsNotification(id, topic string, msg OutgoingMessage, now time.Time) (string, error) {
+	type attribute struct {
+		Type  string `json:"Type"`
+		Value string `json:"Value"`
+	}
+	notification := struct {
+		Type              string               `json:"Type"`
+		MessageId         string               `json:"MessageId"`
+		TopicArn          string               `json:"TopicArn"`
+		Message           string               `json:"Message"`
+		Timestamp         time.Time            `json:"Timestamp"`
+		MessageAttributes map[string]attribute `json:"MessageAttributes,omitempty"`
+	}{
+		Type:      "Notification",
+		MessageId: id,
+		TopicArn:  topic,
+		Message:   msg.Body,
+		Timestamp: now.UTC(),
+	}
+	if len(msg.Attributes) > 0 {
+		notification.MessageAttributes = make(map[string]attribute, len(msg.Attributes))
+		for name, value := range msg.Attributes {
+			notification.MessageAttributes[name] = attribute{Type: "String", Value: value}
+		}
+	}
+	body, err := json.Marshal(notification)
+	return string(body), err
+}
+
+// Send appends msg to queue
+func (b *Memory) Send(ctx context.Context, queue string, msg OutgoingMessage) (string, error) {
+	attributes := make(map[string]string, len(msg.Attributes))
+	for name, value := range msg.Attributes {
+		attributes[name] = value
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	stored := &memoryMessage{
+		Message: Message{
+			ID:         uuid.NewString(),
+			Body:       msg.Body,
+			Attributes: attributes,
+			SentAt:     now,
+		},
+		visibleAt: now,
+	}
+	b.queues[queue] = append(b.queues[queue], stored)
+	return stored.ID, nil
+}
+
+// Receive returns up to opts.MaxMessages visible messages, waiting up to
+// opts.WaitTime for one to arrive when the queue is empty
+func (b *Memory) Receive(ctx context.Context, queue string, opts ReceiveOptions) ([]Message, error) {
+	deadline := b.now().Add(opts.WaitTime)
+	for {
+		if messages := b.receiveVisible(queue, opts); len(messages) > 0 || !b.now().Before(deadline) {
+			return messages, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(memoryPollInterval):
+		}
+	}
+}
+
+func (b *Memory) receiveVisible(queue string, opts ReceiveOptions) []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	max := min(max(opts.MaxMessages, 1), 10)
+	var messages []Message
+	for _, msg := range b.queues[queue] {
+		if len(messages) == max {
+			break
+		}
+		if msg.visibleAt.After(now) {
+			continue
+		}
+		msg.ReceiveCount++
+		msg.Receipt = fmt.Sprintf("%s#%d", msg.ID, msg.ReceiveCount)
+		if !opts.Peek {
+			timeout := opts.VisibilityTimeout
+			if timeout == 0 {
+				timeout = DefaultVisibilityTimeout
+			}
+			msg.visibleAt = now.Add(timeout)
+		}
+
+		received := msg.Message
+		received.Attributes = make(map[string]string, len(msg.Attributes))
+		for name, value := range msg.Attributes {
+			received.Attributes[name] = value
+		}
+		messages = append(messages, received)
+	}
+	return messages
+}
+
+// find returns the index of the message whose latest receipt is receipt
+func (b *Memory) find(queue, receipt string) int {
+	for i, msg := range b.queues[queue] {
+		if msg.Receipt == receipt {
+			return i
+		}
+	}
+	return -1
+}
+
+// Delete removes received messages. A receipt from an earlier delivery of a
+// message that has been received again since is rejected, as SQS does.
+func (b *Memory) Delete(ctx context.Context, queue string, receipts ...string) ([]DeleteFailure, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var failures []DeleteFailure
+	for _, receipt := range receipts {
+		i := b.find(queue, receipt)
+		if i < 0 {
+			failures = append(failures, DeleteFailure{Receipt: receipt, Code: "ReceiptHandleIsInvalid"})
+			continue
+		}
+		b.queues[queue] = append(b.queues[queue][:i], b.queues[queue][i+1:]...)
+	}
+	return failures, nil
+}
+
+// ChangeVisibility hides a received message for timeout from now
+func (b *Memory) ChangeVisibility(ctx context.Context, queue, receipt string, timeout time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i := b.find(queue, receipt)
+	if i < 0 {
+		return fmt.Errorf("receipt %q is not valid for queue %s", receipt, queue)
+	}
+	b.queues[queue][i].visibleAt = b.now().Add(timeout)
+	return nil
+}
+
+// Depth returns the number of messages on queue, including hidden ones
+func (b *Memory) Depth(ctx context.Context, queue string) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.queues[queue]), nil
+}
@@ -0,0 +1,147 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMemoryReceiveHidesMessagesUntilVisibilityTimeout(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemory()
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	b.Send(ctx, "orders", OutgoingMessage{Body: "one", Attributes: map[string]string{"type": "OrderCreated"}})
+
+	first, err := b.Receive(ctx, "orders", ReceiveOptions{MaxMessages: 10, VisibilityTimeout: 30 * time.Second})
+	if err != nil || len(first) != 1 {
+		t.Fatalf("expected one message; got %v, %v", first, err)
+	}
+	if first[0].Body != "one" || first[0].Attributes["type"] != "OrderCreated" || first[0].ReceiveCount != 1 {
+		t.Errorf("unexpected message: %+v", first[0])
+	}
+
+	if hidden, _ := b.Receive(ctx, "orders", ReceiveOptions{MaxMessages: 10}); len(hidden) != 0 {
+		t.Fatalf("expected the message to be hidden; got %v", hidden)
+	}
+
+	now = now.Add(31 * time.Second)
+	again, _ := b.Receive(ctx, "orders", ReceiveOptions{MaxMessages: 10, VisibilityTimeout: 30 * time.Second})
+	if len(again) != 1 || again[0].ReceiveCount != 2 {
+		t.Fatalf("expected the message to be redelivered; got %v", again)
+	}
+
+	// The first receipt is stale once the message has been received again
+	failures, err := b.Delete(ctx, "orders", first[0].Receipt)
+	if err != nil || len(failures) != 1 || failures[0].Retryable {
+		t.Errorf("expected a non-retryable failure for a stale receipt; got %v, %v", failures, err)
+	}
+	if failures, _ := b.Delete(ctx, "orders", again[0].Receipt); len(failures) != 0 {
+		t.Errorf("expected delete to succeed; got %v", failures)
+	}
+	if n, _ := b.Depth(ctx, "orders"); n != 0 {
+		t.Errorf("expected an empty queue; got %d", n)
+	}
+}
+
+func TestMemoryPeekAndDefaultVisibilityTimeout(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemory()
+	now := time.Now()
+	b.now = func() time.Time { return now }
+	b.Send(ctx, "orders", OutgoingMessage{Body: "one"})
+
+	if peeked, _ := b.Receive(ctx, "orders", ReceiveOptions{MaxMessages: 1, Peek: true}); len(peeked) != 1 {
+		t.Fatalf("expected to peek at the message; got %v", peeked)
+	}
+	// Like SQS, a zero timeout means the queue default rather than none
+	if received, _ := b.Receive(ctx, "orders", ReceiveOptions{MaxMessages: 1}); len(received) != 1 {
+		t.Fatalf("expected the message to stay visible after a peek; got %v", received)
+	}
+	if hidden, _ := b.Receive(ctx, "orders", ReceiveOptions{MaxMessages: 1}); len(hidden) != 0 {
+		t.Errorf("expected the default visibility timeout to hide the message; got %v", hidden)
+	}
+	now = now.Add(DefaultVisibilityTimeout + time.Second)
+	if again, _ := b.Receive(ctx, "orders", ReceiveOptions{MaxMessages: 1}); len(again) != 1 {
+		t.Errorf("expected the message to be visible after the default timeout")
+	}
+}
+
+func TestMemoryChangeVisibility(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemory()
+	b.Send(ctx, "orders", OutgoingMessage{Body: "one"})
+
+	received, _ := b.Receive(ctx, "orders", ReceiveOptions{MaxMessages: 1, VisibilityTimeout: time.Hour})
+	if err := b.ChangeVisibility(ctx, "orders", received[0].Receipt, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if again, _ := b.Receive(ctx, "orders", ReceiveOptions{MaxMessages: 1}); len(again) != 1 {
+		t.Errorf("expected the message to be visible again")
+	}
+	if err := b.ChangeVisibility(ctx, "orders", "unknown", 0); err == nil {
+		t.Errorf("expected an error for an unknown receipt")
+	}
+}
+
+func TestMemoryReceiveWaitsForMessages(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemory()
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		b.Send(ctx, "orders", OutgoingMessage{Body: "late"})
+	}()
+	received, err := b.Receive(ctx, "orders", ReceiveOptions{MaxMessages: 1, WaitTime: time.Second})
+	if err != nil || len(received) != 1 {
+		t.Fatalf("expected to receive the late message; got %v, %v", received, err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := b.Receive(cancelled, "empty", ReceiveOptions{WaitTime: time.Second}); err == nil {
+		t.Errorf("expected a cancelled wait to fail")
+	}
+}
+
+func TestMemoryPublishDeliversToSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemory()
+	b.Subscribe(ctx, "orders-topic", "wrapped", false)
+	b.Subscribe(ctx, "orders-topic", "raw", true)
+
+	id, err := b.Publish(ctx, "orders-topic", OutgoingMessage{Body: `{"order_id":"o1"}`, Attributes: map[string]string{"type": "OrderCreated"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	raw, _ := b.Receive(ctx, "raw", ReceiveOptions{MaxMessages: 1})
+	if len(raw) != 1 || raw[0].Body != `{"order_id":"o1"}` || raw[0].Attributes["type"] != "OrderCreated" {
+		t.Errorf("unexpected raw delivery: %+v", raw)
+	}
+
+	wrapped, _ := b.Receive(ctx, "wrapped", ReceiveOptions{MaxMessages: 1})
+	if len(wrapped) != 1 {
+		t.Fatalf("expected a wrapped delivery")
+	}
+	var notification struct {
+		Type              string
+		MessageId         string
+		TopicArn          string
+		Message           string
+		MessageAttributes map[string]struct{ Type, Value string }
+	}
+	if err := json.Unmarshal([]byte(wrapped[0].Body), &notification); err != nil {
+		t.Fatalf("wrapped body is not a notification: %v", err)
+	}
+	if notification.Type != "Notification" || notification.MessageId != id || notification.TopicArn != "orders-topic" ||
+		notification.Message != `{"order_id":"o1"}` || notification.MessageAttributes["type"].Value != "OrderCreated" {
+		t.Errorf("unexpected notification: %+v", notification)
+	}
+
+	if _, err := b.Publish(ctx, "nobody-listens", OutgoingMessage{Body: "lost"}); err != nil {
+		t.Errorf("publishing without subscribers should succeed; got %v", err)
+	}
+}
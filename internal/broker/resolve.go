@@ -0,0 +1,90 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+)
+
+// Resolver turns queue and topic names into the identifiers Publisher and Consumer expect
+type Resolver interface {
+	QueueURL(ctx context.Context, name string) (string, error)
+	TopicARN(ctx context.Context, name string) (string, error)
+}
+
+// Provisioner creates queues, topics and subscriptions. Creating something
+// that already exists returns its identifier.
+type Provisioner interface {
+	CreateQueue(ctx context.Context, name string) (string, error)
+	CreateTopic(ctx context.Context, name string) (string, error)
+	// Subscribe delivers messages published to topic to queue
+	Subscribe(ctx context.Context, topic, queue string, raw bool) error
+}
+
+// Subscription is a SubscriptionSpec with its queues resolved
+type Subscription struct {
+	SubscriptionSpec
+	QueueURL string
+	DLQURL   string
+}
+
+// Resolved holds the identifiers of everything a Config declares
+type Resolved struct {
+	// Topics maps the keys of Config.Topics to topic identifiers
+	Topics        map[string]string
+	Subscriptions []Subscription
+}
+
+// Resolve looks up the queues and topics of cfg by name, creating missing ones
+// and their subscriptions first when cfg.Provision is set
+func Resolve(ctx context.Context, b interface {
+	Resolver
+	Provisioner
+}, cfg *Config) (*Resolved, error) {
+	resolved := &Resolved{Topics: make(map[string]string, len(cfg.Topics))}
+
+	for key, topic := range cfg.Topics {
+		arn := topic.ARN
+		var err error
+		switch {
+		case arn != "":
+		case cfg.Provision:
+			arn, err = b.CreateTopic(ctx, topic.Name)
+		default:
+			arn, err = b.TopicARN(ctx, topic.Name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("topic %s: %w", topic.Name, err)
+		}
+		resolved.Topics[key] = arn
+	}
+
+	queueURL := func(name string) (string, error) {
+		if cfg.Provision {
+			return b.CreateQueue(ctx, name)
+		}
+		return b.QueueURL(ctx, name)
+	}
+	for _, spec := range cfg.Subscriptions {
+		sub := Subscription{SubscriptionSpec: spec}
+		var err error
+		if sub.QueueURL, err = queueURL(spec.Queue); err != nil {
+			return nil, fmt.Errorf("queue %s: %w", spec.Queue, err)
+		}
+		if spec.DeadLetterQueue != "" {
+			if sub.DLQURL, err = queueURL(spec.DeadLetterQueue); err != nil {
+				return nil, fmt.Errorf("queue %s: %w", spec.DeadLetterQueue, err)
+			}
+		}
+		if cfg.Provision && spec.Topic != "" {
+			topicARN, err := b.CreateTopic(ctx, spec.Topic)
+			if err != nil {
+				return nil, fmt.Errorf("topic %s: %w", spec.Topic, err)
+			}
+			if err := b.Subscribe(ctx, topicARN, sub.QueueURL, spec.RawDelivery); err != nil {
+				return nil, fmt.Errorf("subscribing %s to %s: %w", spec.Queue, spec.Topic, err)
+			}
+		}
+		resolved.Subscriptions = append(resolved.Subscriptions, sub)
+	}
+	return resolved, nil
+}
@@ -0,0 +1,326 @@
+// Package config loads the application settings from defaults, an optional
+// YAML or JSON file, a .env file and the environment, in increasing order of
+// precedence, and validates them all at once.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every setting of the application
+type Config struct {
+	Server    ServerConfig    `yaml:"server"`
+	Database  DatabaseConfig  `yaml:"database"`
+	AWS       AWSConfig       `yaml:"aws"`
+	Messaging MessagingConfig `yaml:"messaging"`
+	Jobs      JobsConfig      `yaml:"jobs"`
+	Features  FeatureFlags    `yaml:"features"`
+}
+
+// ServerConfig configures the HTTP server and shutdown
+type ServerConfig struct {
+	Port int `yaml:"port"`
+	// ShutdownTimeout bounds how long in-flight requests get to finish
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// DatabaseConfig configures the PostgreSQL connection
+type DatabaseConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Name     string `yaml:"name"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Schema   string `yaml:"schema"`
+	// MaxOpenConns caps the connections in the pool; zero means no limit
+	MaxOpenConns int `yaml:"max_open_conns"`
+	// MaxIdleConns is how many unused connections the pool keeps open
+	MaxIdleConns int `yaml:"max_idle_conns"`
+	// ConnMaxLifetime and ConnMaxIdleTime close connections after they have
+	// been open, or unused, that long; zero keeps them forever
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+	// AutoMigrate applies pending migrations on startup; without it they are
+	// applied with the migrate command
+	AutoMigrate bool `yaml:"auto_migrate"`
+}
+
+// DSN returns the connection string for the pgx driver
+func (c DatabaseConfig) DSN() string {
+	return fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=disable&search_path=%s", c.Username, c.Password, c.Host, c.Port, c.Name, c.Schema)
+}
+
+// AWSConfig selects and configures the message broker
+type AWSConfig struct {
+	// Broker is "aws" for SNS and SQS, or "memory" for the in-process broker
+	Broker string `yaml:"broker"`
+	Region string `yaml:"region"`
+	// EndpointURL overrides the AWS endpoints, for example to use LocalStack
+	EndpointURL string `yaml:"endpoint_url"`
+}
+
+// MessagingConfig configures message consumption
+type MessagingConfig struct {
+	// ConfigPath is the file declaring queues, topics and subscriptions
+	ConfigPath string `yaml:"config_path"`
+	// Fallback is what happens to events without a handler: error, drop or dlq
+	Fallback string `yaml:"fallback"`
+	// DrainTimeout bounds how long in-flight messages get to finish at shutdown
+	DrainTimeout time.Duration `yaml:"drain_timeout"`
+}
+
+// JobsConfig configures the background jobs
+type JobsConfig struct {
+	ProductPurgeRetention    time.Duration `yaml:"product_purge_retention"`
+	ProductPurgeInterval     time.Duration `yaml:"product_purge_interval"`
+	ReservationTTL           time.Duration `yaml:"reservation_ttl"`
+	ReservationMaxTTL        time.Duration `yaml:"reservation_max_ttl"`
+	ReservationSweepInterval time.Duration `yaml:"reservation_sweep_interval"`
+	InboxRetention           time.Duration `yaml:"inbox_retention"`
+	InboxCleanupInterval     time.Duration `yaml:"inbox_cleanup_interval"`
+	OutboxRelayInterval      time.Duration `yaml:"outbox_relay_interval"`
+	OutboxRetention          time.Duration `yaml:"outbox_retention"`
+	OutboxCleanupInterval    time.Duration `yaml:"outbox_cleanup_interval"`
+}
+
+// FeatureFlags switch optional parts of the application on and off
+type FeatureFlags struct {
+	// MessageProcessing consumes the queues declared in the messaging config
+	MessageProcessing bool `yaml:"message_processing"`
+	// OutboxRelay publishes recorded domain events
+	OutboxRelay bool `yaml:"outbox_relay"`
+	// AdminEndpoints exposes the /admin routes
+	AdminEndpoints bool `yaml:"admin_endpoints"`
+}
+
+// Default returns the settings used when nothing overrides them
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:            8080,
+			ShutdownTimeout: 5 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Port:            5432,
+			Schema:          "public",
+			MaxOpenConns:    25,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			AutoMigrate:     true,
+		},
+		AWS: AWSConfig{
+			Broker: "aws",
+		},
+		Messaging: MessagingConfig{
+			ConfigPath:   "config/messaging.yaml",
+			Fallback:     "dlq",
+			DrainTimeout: 30 * time.Second,
+		},
+		Jobs: JobsConfig{
+			ProductPurgeRetention:    30 * 24 * time.Hour,
+			ProductPurgeInterval:     time.Hour,
+			ReservationTTL:           15 * time.Minute,
+			ReservationMaxTTL:        24 * time.Hour,
+			ReservationSweepInterval: time.Minute,
+			// SQS keeps messages for at most 14 days
+			InboxRetention:        14 * 24 * time.Hour,
+			InboxCleanupInterval:  time.Hour,
+			OutboxRelayInterval:   time.Second,
+			OutboxRetention:       7 * 24 * time.Hour,
+			OutboxCleanupInterval: time.Hour,
+		},
+		Features: FeatureFlags{
+			MessageProcessing: true,
+			OutboxRelay:       true,
+			AdminEndpoints:    true,
+		},
+	}
+}
+
+// ValidationError lists every invalid setting found while loading
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Load builds the configuration. The file named by CONFIG_FILE is read if
+// set, then the environment, which includes .env when present. It returns a
+// *ValidationError listing every problem when any setting is invalid.
+func Load() (*Config, error) {
+	return load((*Config).validate)
+}
+
+// LoadDatabase is Load for commands that only use the database; settings
+// outside DatabaseConfig are not validated.
+func LoadDatabase() (DatabaseConfig, error) {
+	cfg, err := load(func(c *Config) []string { return c.Database.validate() })
+	if err != nil {
+		return DatabaseConfig{}, err
+	}
+	return cfg.Database, nil
+}
+
+func load(validate func(*Config) []string) (*Config, error) {
+	// Variables already set in the environment win over .env
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("loading .env: %w", err)
+	}
+
+	cfg := Default()
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	e := &env{}
+	cfg.applyEnv(e)
+	problems := append(e.problems, validate(cfg)...)
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return cfg, nil
+}
+
+// loadFile overlays a YAML or JSON file on cfg. JSON is parsed as YAML, of
+// which it is a subset.
+func (c *Config) loadFile(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	decoder := yaml.NewDecoder(strings.NewReader(string(raw)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) applyEnv(e *env) {
+	e.int("PORT", &c.Server.Port)
+	e.duration("SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout)
+
+	e.string("DB_HOST", &c.Database.Host)
+	e.int("DB_PORT", &c.Database.Port)
+	e.string("DB_DATABASE", &c.Database.Name)
+	e.string("DB_USERNAME", &c.Database.Username)
+	e.string("DB_PASSWORD", &c.Database.Password)
+	e.string("DB_SCHEMA", &c.Database.Schema)
+	e.int("DB_MAX_OPEN_CONNS", &c.Database.MaxOpenConns)
+	e.int("DB_MAX_IDLE_CONNS", &c.Database.MaxIdleConns)
+	e.duration("DB_CONN_MAX_LIFETIME", &c.Database.ConnMaxLifetime)
+	e.duration("DB_CONN_MAX_IDLE_TIME", &c.Database.ConnMaxIdleTime)
+	e.bool("DB_AUTO_MIGRATE", &c.Database.AutoMigrate)
+
+	e.string("BROKER", &c.AWS.Broker)
+	e.string("AWS_REGION", &c.AWS.Region)
+	e.string("AWS_ENDPOINT_URL", &c.AWS.EndpointURL)
+
+	e.string("MESSAGING_CONFIG", &c.Messaging.ConfigPath)
+	e.string("MESSAGE_FALLBACK", &c.Messaging.Fallback)
+	e.duration("MESSAGE_DRAIN_TIMEOUT", &c.Messaging.DrainTimeout)
+
+	e.duration("PRODUCT_PURGE_RETENTION", &c.Jobs.ProductPurgeRetention)
+	e.duration("PRODUCT_PURGE_INTERVAL", &c.Jobs.ProductPurgeInterval)
+	e.duration("RESERVATION_TTL", &c.Jobs.ReservationTTL)
+	e.duration("RESERVATION_MAX_TTL", &c.Jobs.ReservationMaxTTL)
+	e.duration("RESERVATION_SWEEP_INTERVAL", &c.Jobs.ReservationSweepInterval)
+	e.duration("INBOX_RETENTION", &c.Jobs.InboxRetention)
+	e.duration("INBOX_CLEANUP_INTERVAL", &c.Jobs.InboxCleanupInterval)
+	e.duration("OUTBOX_RELAY_INTERVAL", &c.Jobs.OutboxRelayInterval)
+	e.duration("OUTBOX_RETENTION", &c.Jobs.OutboxRetention)
+	e.duration("OUTBOX_CLEANUP_INTERVAL", &c.Jobs.OutboxCleanupInterval)
+
+	e.bool("FEATURE_MESSAGE_PROCESSING", &c.Features.MessageProcessing)
+	e.bool("FEATURE_OUTBOX_RELAY", &c.Features.OutboxRelay)
+	e.bool("FEATURE_ADMIN_ENDPOINTS", &c.Features.AdminEndpoints)
+}
+
+// validate returns a description of every invalid database setting
+func (c DatabaseConfig) validate() []string {
+	var problems []string
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Host != "", "database host is required (DB_HOST)")
+	check(c.Port > 0 && c.Port <= 65535, "database port %d is out of range", c.Port)
+	check(c.Name != "", "database name is required (DB_DATABASE)")
+	check(c.Username != "", "database username is required (DB_USERNAME)")
+	check(c.Schema != "", "database schema is required (DB_SCHEMA)")
+	check(c.MaxOpenConns >= 0, "database max open connections must not be negative")
+	check(c.MaxIdleConns >= 0, "database max idle connections must not be negative")
+	check(c.MaxOpenConns == 0 || c.MaxIdleConns <= c.MaxOpenConns,
+		"database max idle connections (%d) exceed max open connections (%d)", c.MaxIdleConns, c.MaxOpenConns)
+	check(c.ConnMaxLifetime >= 0, "database connection max lifetime must not be negative")
+	check(c.ConnMaxIdleTime >= 0, "database connection max idle time must not be negative")
+	return problems
+}
+
+// validate returns a description of every invalid setting
+func (c *Config) validate() []string {
+	var problems []string
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port <= 65535, "server port %d is out of range", c.Server.Port)
+	check(c.Server.ShutdownTimeout > 0, "server shutdown timeout must be positive")
+
+	problems = append(problems, c.Database.validate()...)
+
+	switch c.AWS.Broker {
+	case "aws":
+		needsAWS := c.Features.MessageProcessing || c.Features.OutboxRelay
+		check(c.AWS.Region != "" || !needsAWS, "AWS region is required when the broker is aws (AWS_REGION)")
+	case "memory":
+	default:
+		problems = append(problems, fmt.Sprintf("broker %q must be aws or memory", c.AWS.Broker))
+	}
+
+	check(c.Messaging.ConfigPath != "" || !c.Features.MessageProcessing, "messaging config path is required (MESSAGING_CONFIG)")
+	switch strings.ToLower(c.Messaging.Fallback) {
+	case "error", "drop", "dlq":
+	default:
+		problems = append(problems, fmt.Sprintf("message fallback %q must be error, drop or dlq", c.Messaging.Fallback))
+	}
+	check(c.Messaging.DrainTimeout > 0, "message drain timeout must be positive")
+
+	for _, job := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"product purge retention", c.Jobs.ProductPurgeRetention},
+		{"product purge interval", c.Jobs.ProductPurgeInterval},
+		{"reservation TTL", c.Jobs.ReservationTTL},
+		{"reservation max TTL", c.Jobs.ReservationMaxTTL},
+		{"reservation sweep interval", c.Jobs.ReservationSweepInterval},
+		{"inbox retention", c.Jobs.InboxRetention},
+		{"inbox cleanup interval", c.Jobs.InboxCleanupInterval},
+		{"outbox relay interval", c.Jobs.OutboxRelayInterval},
+		{"outbox retention", c.Jobs.OutboxRetention},
+		{"outbox cleanup interval", c.Jobs.OutboxCleanupInterval},
+	} {
+		check(job.value > 0, "%s must be positive", job.name)
+	}
+	check(c.Jobs.ReservationTTL <= c.Jobs.ReservationMaxTTL,
+		"reservation TTL (%s) exceeds reservation max TTL (%s)", c.Jobs.ReservationTTL, c.Jobs.ReservationMaxTTL)
+	return problems
+}
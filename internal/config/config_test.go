@@ -0,0 +1,128 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setRequired sets the settings that have no default
+func setRequired(t *testing.T) {
+	t.Helper()
+	t.Setenv("DB_HOST", "localhost")
+	t.Setenv("DB_DATABASE", "products")
+	t.Setenv("DB_USERNAME", "app")
+	t.Setenv("AWS_REGION", "us-east-1")
+}
+
+func TestLoadDefaultsAndEnv(t *testing.T) {
+	setRequired(t)
+	t.Setenv("PORT", "9090")
+	t.Setenv("DB_PORT", "6543")
+	t.Setenv("RESERVATION_TTL", "5m")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "1h")
+	t.Setenv("FEATURE_ADMIN_ENDPOINTS", "false")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Server.Port != 9090 || cfg.Database.Port != 6543 || cfg.Database.ConnMaxLifetime != time.Hour || cfg.Jobs.ReservationTTL != 5*time.Minute {
+		t.Errorf("env overrides not applied: %+v", cfg)
+	}
+	if cfg.Features.AdminEndpoints || !cfg.Features.MessageProcessing {
+		t.Errorf("unexpected feature flags: %+v", cfg.Features)
+	}
+	if cfg.Messaging.DrainTimeout != 30*time.Second || cfg.Database.Schema != "public" {
+		t.Errorf("defaults not kept: %+v", cfg)
+	}
+	if want := "postgres://app:@localhost:6543/products?sslmode=disable&search_path=public"; cfg.Database.DSN() != want {
+		t.Errorf("DSN() = %q, want %q", cfg.Database.DSN(), want)
+	}
+}
+
+func TestLoadListsEveryProblem(t *testing.T) {
+	setRequired(t)
+	t.Setenv("DB_HOST", "")
+	t.Setenv("PORT", "http")
+	t.Setenv("BROKER", "kafka")
+	t.Setenv("MESSAGE_FALLBACK", "retry")
+	t.Setenv("OUTBOX_RELAY_INTERVAL", "-1s")
+	t.Setenv("DB_MAX_OPEN_CONNS", "5")
+	t.Setenv("DB_MAX_IDLE_CONNS", "10")
+	t.Setenv("RESERVATION_MAX_TTL", "1m")
+
+	_, err := Load()
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected *ValidationError, got %v", err)
+	}
+	for _, want := range []string{"PORT", "database host", "broker", "fallback", "outbox relay interval", "max idle connections", "reservation max TTL"} {
+		found := false
+		for _, problem := range invalid.Problems {
+			found = found || strings.Contains(problem, want)
+		}
+		if !found {
+			t.Errorf("no problem mentions %q in %v", want, invalid.Problems)
+		}
+	}
+}
+
+func TestLoadRegionOnlyRequiredForMessaging(t *testing.T) {
+	setRequired(t)
+	t.Setenv("AWS_REGION", "")
+	if _, err := Load(); err == nil {
+		t.Fatal("expected an error without a region")
+	}
+
+	t.Setenv("FEATURE_MESSAGE_PROCESSING", "false")
+	t.Setenv("FEATURE_OUTBOX_RELAY", "false")
+	if _, err := Load(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	setRequired(t)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+server:
+  port: 7070
+  shutdown_timeout: 10s
+messaging:
+  fallback: drop
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	// The environment wins over the file
+	t.Setenv("MESSAGE_FALLBACK", "error")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Server.Port != 7070 || cfg.Server.ShutdownTimeout != 10*time.Second {
+		t.Errorf("file not applied: %+v", cfg.Server)
+	}
+	if cfg.Messaging.Fallback != "error" {
+		t.Errorf("Fallback = %q, want error", cfg.Messaging.Fallback)
+	}
+}
+
+func TestLoadFileRejectsUnknownFields(t *testing.T) {
+	setRequired(t)
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"server": {"prot": 7070}}`), 0o600); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+
+	if _, err := Load(); err == nil {
+		t.Fatal("expected an error for an unknown field")
+	}
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// env reads typed values from environment variables, recording a problem for
+// every value that does not parse instead of stopping at the first one
+type env struct {
+	problems []string
+}
+
+func (e *env) lookup(key string) (string, bool) {
+	value, ok := os.LookupEnv(key)
+	return value, ok && value != ""
+}
+
+func (e *env) invalid(key, value, want string) {
+	e.problems = append(e.problems, fmt.Sprintf("%s=%q is not %s", key, value, want))
+}
+
+func (e *env) string(key string, dst *string) {
+	if value, ok := e.lookup(key); ok {
+		*dst = value
+	}
+}
+
+func (e *env) int(key string, dst *int) {
+	value, ok := e.lookup(key)
+	if !ok {
+		return
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		e.invalid(key, value, "an integer")
+		return
+	}
+	*dst = n
+}
+
+func (e *env) bool(key string, dst *bool) {
+	value, ok := e.lookup(key)
+	if !ok {
+		return
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		e.invalid(key, value, "a boolean")
+		return
+	}
+	*dst = b
+}
+
+func (e *env) duration(key string, dst *time.Duration) {
+	value, ok := e.lookup(key)
+	if !ok {
+		return
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		e.invalid(key, value, "a duration such as 30s or 720h")
+		return
+	}
+	*dst = d
+}
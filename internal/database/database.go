@@ -5,12 +5,12 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	"os"
 	"strconv"
 	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
-	_ "github.com/joho/godotenv/autoload"
+
+	"products-api/internal/config"
 )
 
 // Service represents a service that interacts with a database.
@@ -19,6 +19,9 @@ type Service interface {
 	// The keys and values in the map are service-specific.
 	Health() map[string]string
 
+	// Ping checks that the database can be reached within ctx.
+	Ping(ctx context.Context) error
+
 	// Close terminates the database connection.
 	// It returns an error if the connection cannot be closed.
 	Close() error
@@ -28,48 +31,45 @@ type Service interface {
 }
 
 type service struct {
-	db *sql.DB
+	db  *sql.DB
+	cfg config.DatabaseConfig
 }
 
-var (
-	database   = os.Getenv("DB_DATABASE")
-	password   = os.Getenv("DB_PASSWORD")
-	username   = os.Getenv("DB_USERNAME")
-	port       = os.Getenv("DB_PORT")
-	host       = os.Getenv("DB_HOST")
-	schema     = os.Getenv("DB_SCHEMA")
-	DBInstance *service
-)
-
-func New() Service {
-	// Reuse Connection
-	if DBInstance != nil {
-		return DBInstance
-	}
-	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable&search_path=%s", username, password, host, port, database, schema)
-	db, err := sql.Open("pgx", connStr)
+// New opens a connection pool to the database described by cfg, checks that
+// it is reachable and, if cfg.AutoMigrate is set, applies pending migrations.
+// Every call returns a separate pool, which the caller closes.
+func New(cfg config.DatabaseConfig) (Service, error) {
+	db, err := sql.Open("pgx", cfg.DSN())
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("opening database: %w", err)
 	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
 	// Test the connection
 	if err := db.Ping(); err != nil {
-		log.Fatal("Failed to ping database:", err)
+		db.Close()
+		return nil, fmt.Errorf("pinging database: %w", err)
 	}
 
-	// Run migrations
-	if err := RunMigrations(db); err != nil {
-		log.Fatal("Failed to run migrations:", err)
+	if cfg.AutoMigrate {
+		if err := RunMigrations(db); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("running migrations: %w", err)
+		}
 	}
 
-	DBInstance = &service{
-		db: db,
-	}
-	return DBInstance
+	return &service{
+		db:  db,
+		cfg: cfg,
+	}, nil
 }
 
 // Health checks the health of the database connection by pinging the database.
-// It returns a map with keys indicating various health statistics.
+// It returns a map with keys indicating various health statistics. A failed
+// ping is reported with status "down"; it is not fatal.
 func (s *service) Health() map[string]string {
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
@@ -77,11 +77,11 @@ func (s *service) Health() map[string]string {
 	stats := make(map[string]string)
 
 	// Ping the database
-	err := s.db.PingContext(ctx)
+	err := s.Ping(ctx)
 	if err != nil {
 		stats["status"] = "down"
 		stats["error"] = fmt.Sprintf("db down: %v", err)
-		log.Fatalf("db down: %v", err) // Log the error and terminate the program
+		log.Printf("db down: %v", err)
 		return stats
 	}
 
@@ -100,7 +100,7 @@ func (s *service) Health() map[string]string {
 	stats["max_lifetime_closed"] = strconv.FormatInt(dbStats.MaxLifetimeClosed, 10)
 
 	// Evaluate stats to provide a health message
-	if dbStats.OpenConnections > 40 { // Assuming 50 is the max for this example
+	if maxOpen := s.cfg.MaxOpenConns; maxOpen > 0 && dbStats.OpenConnections > maxOpen*4/5 {
 		stats["message"] = "The database is experiencing heavy load."
 	}
 
@@ -119,12 +119,17 @@ func (s *service) Health() map[string]string {
 	return stats
 }
 
+// Ping checks that the database can be reached within ctx.
+func (s *service) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
 // Close closes the database connection.
 // It logs a message indicating the disconnection from the specific database.
 // If the connection is successfully closed, it returns nil.
 // If an error occurs while closing the connection, it returns the error.
 func (s *service) Close() error {
-	log.Printf("Disconnected from database: %s", database)
+	log.Printf("Disconnected from database: %s", s.cfg.Name)
 	return s.db.Close()
 }
 
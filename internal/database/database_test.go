@@ -9,8 +9,12 @@ import (
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/postgres"
 	"github.com/testcontainers/testcontainers-go/wait"
+
+	"products-api/internal/config"
 )
 
+var testConfig config.DatabaseConfig
+
 func mustStartPostgresContainer() (func(context.Context, ...testcontainers.TerminateOption) error, error) {
 	var (
 		dbName = "database"
@@ -33,9 +37,9 @@ func mustStartPostgresContainer() (func(context.Context, ...testcontainers.Termi
 		return nil, err
 	}
 
-	database = dbName
-	password = dbPwd
-	username = dbUser
+	testConfig.Name = dbName
+	testConfig.Password = dbPwd
+	testConfig.Username = dbUser
 
 	dbHost, err := dbContainer.Host(context.Background())
 	if err != nil {
@@ -47,9 +51,12 @@ func mustStartPostgresContainer() (func(context.Context, ...testcontainers.Termi
 		return dbContainer.Terminate, err
 	}
 
-	host = dbHost
-	port = dbPort.Port()
-	schema = "public"
+	testConfig.Host = dbHost
+	testConfig.Port = dbPort.Int()
+	testConfig.Schema = "public"
+	testConfig.MaxOpenConns = 5
+	testConfig.MaxIdleConns = 5
+	testConfig.AutoMigrate = true
 
 	return dbContainer.Terminate, err
 }
@@ -67,15 +74,37 @@ func TestMain(m *testing.M) {
 	}
 }
 
+// newTestService opens a pool of its own, so tests can run in parallel
+func newTestService(t *testing.T) Service {
+	t.Helper()
+	srv, err := New(testConfig)
+	if err != nil {
+		t.Fatalf("New(testConfig) returned error: %v", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv
+}
+
 func TestNew(t *testing.T) {
-	srv := New()
-	if srv == nil {
-		t.Fatal("New() returned nil")
+	t.Parallel()
+	first, second := newTestService(t), newTestService(t)
+	if first.GetDB() == second.GetDB() {
+		t.Fatal("expected every call to New to open a separate pool")
+	}
+}
+
+func TestNewUnreachable(t *testing.T) {
+	t.Parallel()
+	cfg := testConfig
+	cfg.Password = "wrong"
+	if _, err := New(cfg); err == nil {
+		t.Fatal("expected an error for invalid credentials")
 	}
 }
 
 func TestHealth(t *testing.T) {
-	srv := New()
+	t.Parallel()
+	srv := newTestService(t)
 
 	stats := srv.Health()
 
@@ -93,7 +122,11 @@ func TestHealth(t *testing.T) {
 }
 
 func TestClose(t *testing.T) {
-	srv := New()
+	t.Parallel()
+	srv, err := New(testConfig)
+	if err != nil {
+		t.Fatalf("New(testConfig) returned error: %v", err)
+	}
 
 	if srv.Close() != nil {
 		t.Fatalf("expected Close() to return nil")
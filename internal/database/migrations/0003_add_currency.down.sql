@@ -0,0 +1,5 @@
+ALTER TABLE orders DROP COLUMN currency;
+ALTER TABLE orders ALTER COLUMN total_price TYPE DECIMAL(12,2);
+
+ALTER TABLE products DROP COLUMN currency;
+ALTER TABLE products ALTER COLUMN price TYPE DECIMAL(10,2);
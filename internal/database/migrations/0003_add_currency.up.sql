@@ -0,0 +1,8 @@
+-- Prices are stored with three decimal places, the most minor unit digits of
+-- any supported currency, next to the ISO 4217 code of their currency.
+-- Existing prices were all in US dollars.
+ALTER TABLE products ALTER COLUMN price TYPE NUMERIC(11,3);
+ALTER TABLE products ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+ALTER TABLE orders ALTER COLUMN total_price TYPE NUMERIC(14,3);
+ALTER TABLE orders ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
@@ -0,0 +1,23 @@
+-- Prices of a product in other currencies, optionally per price list. They
+-- take precedence over converting the product's own price.
+CREATE TABLE product_prices (
+    product_id VARCHAR(255) NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    price_list VARCHAR(100) NOT NULL DEFAULT 'default',
+    currency CHAR(3) NOT NULL,
+    amount NUMERIC(11,3) NOT NULL CHECK (amount >= 0),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (product_id, price_list, currency)
+);
+
+-- Units of quote_currency one unit of base_currency buys, from effective_at
+-- until a later rate for the pair takes effect
+CREATE TABLE exchange_rates (
+    base_currency CHAR(3) NOT NULL,
+    quote_currency CHAR(3) NOT NULL,
+    rate NUMERIC(20,10) NOT NULL CHECK (rate > 0),
+    effective_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (base_currency, quote_currency, effective_at),
+    CHECK (base_currency <> quote_currency)
+);
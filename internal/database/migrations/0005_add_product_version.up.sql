@@ -0,0 +1,4 @@
+-- version counts the writes to a product. It is incremented by every UPDATE
+-- and served as the product's ETag, so clients can make their writes
+-- conditional on the version they read.
+ALTER TABLE products ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0002_second.up.sql":   {Data: []byte("CREATE TABLE b (id INT);")},
+		"m/0002_second.down.sql": {Data: []byte("DROP TABLE b;")},
+		"m/0001_first.up.sql":    {Data: []byte("CREATE TABLE a (id INT);")},
+		"m/0001_first.down.sql":  {Data: []byte("DROP TABLE a;")},
+		"m/README.md":            {Data: []byte("ignored")},
+	}
+
+	migrations, err := LoadMigrations(fsys, "m")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != 1 || migrations[1].Name != "second" {
+		t.Fatalf("unexpected migrations: %+v", migrations)
+	}
+	if migrations[0].Down != "DROP TABLE a;" {
+		t.Errorf("unexpected down script: %q", migrations[0].Down)
+	}
+
+	delete(fsys, "m/0002_second.down.sql")
+	if _, err := LoadMigrations(fsys, "m"); err == nil {
+		t.Error("expected an error for a migration without a down script")
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := LoadMigrations(migrationFiles, "migrations")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, m := range migrations {
+		if m.Version != int64(i+1) {
+			t.Errorf("migration %s has version %d, want %d", m.Name, m.Version, i+1)
+		}
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	srv := newTestService(t)
+	migrator, err := NewMigrator(srv.GetDB())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+
+	// New already migrated; replicas starting together must not race
+	var wg sync.WaitGroup
+	errs := make(chan error, 3)
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- migrator.Up(ctx)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent Up failed: %v", err)
+		}
+	}
+
+	if err := migrator.Down(ctx); err != nil {
+		t.Fatalf("Down failed: %v", err)
+	}
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	last := statuses[len(statuses)-1]
+	if last.Applied || !statuses[0].Applied {
+		t.Errorf("expected only the latest migration to be rolled back: %+v", statuses)
+	}
+
+	if err := migrator.To(ctx, migrator.Latest()); err != nil {
+		t.Fatalf("To failed: %v", err)
+	}
+	if err := migrator.To(ctx, 999); !errors.Is(err, ErrUnknownMigration) {
+		t.Errorf("expected ErrUnknownMigration, got %v", err)
+	}
+
+	// Editing an applied migration is refused
+	if _, err := srv.GetDB().Exec(`UPDATE schema_migrations SET checksum = repeat('0', 64) WHERE version = 1`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer srv.GetDB().Exec(`UPDATE schema_migrations SET checksum = $1 WHERE version = 1`, migrator.migrations[0].Checksum())
+	if err := migrator.Up(ctx); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected ErrChecksumMismatch, got %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package events
+
+import (
+	"encoding/json"
+	"time"
+
+	"products-api/internal/models"
+)
+
+// Envelope is the JSON body of every event this service publishes.
+type Envelope struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// NewOutboxMessage wraps data in an Envelope and prepares it for the outbox.
+// aggregateID identifies the entity the event is about; events for the same
+// aggregate are published in the order they were recorded.
+func NewOutboxMessage(eventType, aggregateID string, data any) (*models.OutboxMessage, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	envelope := Envelope{
+		ID:         models.NewID(),
+		Type:       eventType,
+		OccurredAt: time.Now().UTC(),
+		Data:       raw,
+	}
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, err
+	}
+	return &models.OutboxMessage{
+		EventID:     envelope.ID,
+		AggregateID: aggregateID,
+		EventType:   eventType,
+		Payload:     payload,
+	}, nil
+}
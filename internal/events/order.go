@@ -0,0 +1,50 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"products-api/internal/validation"
+)
+
+// OrderCreatedType identifies OrderCreated messages.
+const OrderCreatedType = "OrderCreated"
+
+// ErrInvalidEvent is wrapped by every event validation error.
+var ErrInvalidEvent = errors.New("invalid event")
+
+// OrderLineItem is one product and the number of units ordered.
+type OrderLineItem struct {
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+}
+
+// OrderCreated is published by the order service when a customer places an order.
+type OrderCreated struct {
+	OrderID string          `json:"order_id"`
+	Items   []OrderLineItem `json:"items"`
+}
+
+// Validate checks that the order has an ID and at least one line item with a
+// product ID and a positive quantity. The error wraps ErrInvalidEvent and
+// validation.Errors listing every invalid field.
+func (o OrderCreated) Validate() error {
+	checks := []validation.Check{
+		validation.Field("order_id", o.OrderID, validation.Required),
+		validation.Field("items", o.Items, validation.NotEmpty[OrderLineItem]),
+	}
+	for i, item := range o.Items {
+		checks = append(checks, validation.Nested(fmt.Sprintf("items[%d]", i), item.Validate()))
+	}
+	if err := validation.Validate(checks...); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidEvent, err)
+	}
+	return nil
+}
+
+// Validate checks that the line item names a product and a positive quantity
+func (i OrderLineItem) Validate() error {
+	return validation.Validate(
+		validation.Field("product_id", i.ProductID, validation.Required),
+		validation.Field("quantity", i.Quantity, validation.Positive[int]),
+	)
+}
@@ -0,0 +1,32 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderCreatedValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		order OrderCreated
+		valid bool
+	}{
+		{name: "valid", order: OrderCreated{OrderID: "o1", Items: []OrderLineItem{{ProductID: "p1", Quantity: 2}}}, valid: true},
+		{name: "missing order id", order: OrderCreated{Items: []OrderLineItem{{ProductID: "p1", Quantity: 2}}}},
+		{name: "no items", order: OrderCreated{OrderID: "o1"}},
+		{name: "missing product id", order: OrderCreated{OrderID: "o1", Items: []OrderLineItem{{Quantity: 2}}}},
+		{name: "zero quantity", order: OrderCreated{OrderID: "o1", Items: []OrderLineItem{{ProductID: "p1"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.order.Validate()
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidEvent)
+			}
+		})
+	}
+}
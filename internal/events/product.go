@@ -0,0 +1,45 @@
+package events
+
+import "products-api/internal/models"
+
+// Product event types published through the outbox.
+const (
+	ProductCreatedType = "ProductCreated"
+	ProductUpdatedType = "ProductUpdated"
+	ProductDeletedType = "ProductDeleted"
+	StockChangedType   = "StockChanged"
+	OutOfStockType     = "OutOfStock"
+)
+
+// Reasons reported in StockChanged events.
+const (
+	StockReasonOrder       = "order"
+	StockReasonSale        = "sale"
+	StockReasonReservation = "reservation_confirmed"
+	StockReasonUpdate      = "update"
+)
+
+// ProductChanged is the payload of ProductCreated and ProductUpdated events.
+type ProductChanged struct {
+	Product models.Product `json:"product"`
+}
+
+// ProductDeleted is published when a product is soft deleted.
+type ProductDeleted struct {
+	ProductID string `json:"product_id"`
+}
+
+// StockChanged is published whenever a product's on-hand quantity changes.
+type StockChanged struct {
+	ProductID         string `json:"product_id"`
+	Quantity          int    `json:"quantity"`
+	ReservedQuantity  int    `json:"reserved_quantity"`
+	AvailableQuantity int    `json:"available_quantity"`
+	Delta             int    `json:"delta"`
+	Reason            string `json:"reason"`
+}
+
+// OutOfStock is published when a change leaves a product with no available units.
+type OutOfStock struct {
+	ProductID string `json:"product_id"`
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"fmt"
+	"products-api/internal/apperr"
+	"products-api/internal/repository"
+	"products-api/internal/services"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type OrderHandler struct {
+	orderService *services.OrderService
+}
+
+func NewOrderHandler(orderService *services.OrderService) *OrderHandler {
+	return &OrderHandler{orderService: orderService}
+}
+
+// CreateOrder buys units of a product, taking them from stock.
+func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
+	var body struct {
+		ProductID string `json:"product_id"`
+		Quantity  int    `json:"quantity"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return apperr.ErrInvalidBody
+	}
+
+	order, err := h.orderService.PlaceOrder(c.Context(), body.ProductID, body.Quantity)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusCreated).JSON(order)
+}
+
+func (h *OrderHandler) GetOrder(c *fiber.Ctx) error {
+	order, err := h.orderService.GetOrder(c.Context(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(order)
+}
+
+// GetOrders lists orders newest first, optionally only those of one product.
+func (h *OrderHandler) GetOrders(c *fiber.Ctx) error {
+	query := repository.OrderQuery{
+		Cursor:    c.Query("cursor"),
+		ProductID: c.Query("product_id"),
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > repository.MaxPageSize {
+			return apperr.InvalidParameter(fmt.Sprintf("limit must be between 1 and %d", repository.MaxPageSize))
+		}
+		query.Limit = n
+	}
+
+	page, err := h.orderService.ListOrders(c.Context(), query)
+	if err != nil {
+		return err
+	}
+	return c.JSON(page)
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"products-api/internal/apperr"
+	"products-api/internal/models"
+	"products-api/internal/money"
+	"products-api/internal/services"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PriceHandler struct {
+	pricingService *services.PricingService
+}
+
+func NewPriceHandler(pricingService *services.PricingService) *PriceHandler {
+	return &PriceHandler{pricingService: pricingService}
+}
+
+// SetPrice sets the price of the product in the currency of the path on the
+// price list named in the body.
+func (h *PriceHandler) SetPrice(c *fiber.Ctx) error {
+	currency, err := currencyParam(c.Params("currency"), "currency")
+	if err != nil {
+		return err
+	}
+	var req SetPriceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return apperr.ErrInvalidBody
+	}
+	amount := PriceRequest{Amount: req.Amount, Currency: currency}
+	if err := amount.Validate(); err != nil {
+		return err
+	}
+
+	price := models.ProductPrice{ProductID: c.Params("id"), PriceList: req.PriceList, Price: amount.Money()}
+	if err := h.pricingService.SetPrice(c.Context(), &price); err != nil {
+		return err
+	}
+	return c.JSON(price)
+}
+
+// GetPrices lists the price list entries of the product.
+func (h *PriceHandler) GetPrices(c *fiber.Ctx) error {
+	prices, err := h.pricingService.ListPrices(c.Context(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{"items": prices})
+}
+
+// DeletePrice removes the price of the product in the currency of the path on
+// ?price_list=, after which the product's own price is converted instead.
+func (h *PriceHandler) DeletePrice(c *fiber.Ctx) error {
+	currency, err := currencyParam(c.Params("currency"), "currency")
+	if err != nil {
+		return err
+	}
+	if err := h.pricingService.DeletePrice(c.Context(), c.Params("id"), c.Query("price_list"), currency); err != nil {
+		return err
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// CreateExchangeRate stores an exchange rate used to convert prices that have
+// no price list entry in the requested currency.
+func (h *PriceHandler) CreateExchangeRate(c *fiber.Ctx) error {
+	var req ExchangeRateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return apperr.ErrInvalidBody
+	}
+
+	rate := req.ExchangeRate()
+	if err := h.pricingService.SetExchangeRate(c.Context(), &rate); err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusCreated).JSON(rate)
+}
+
+// GetExchangeRates lists exchange rates, optionally only those from ?base= or to ?quote=.
+func (h *PriceHandler) GetExchangeRates(c *fiber.Ctx) error {
+	var filters [2]money.Currency
+	for i, param := range []string{"base", "quote"} {
+		if value := c.Query(param); value != "" {
+			currency, err := currencyParam(value, param)
+			if err != nil {
+				return err
+			}
+			filters[i] = currency
+		}
+	}
+
+	rates, err := h.pricingService.ListExchangeRates(c.Context(), filters[0], filters[1])
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{"items": rates})
+}
+
+// currencyParam reads a currency code from the named path or query parameter
+func currencyParam(value, name string) (money.Currency, error) {
+	currency := money.Currency(strings.ToUpper(value))
+	if !currency.Valid() {
+		return "", apperr.InvalidParameter(name + " must be a supported ISO 4217 code")
+	}
+	return currency, nil
+}
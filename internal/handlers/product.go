@@ -1,38 +1,362 @@
 package handlers
 
 import (
+	"fmt"
+	"products-api/internal/apperr"
 	"products-api/internal/models"
+	"products-api/internal/money"
+	"products-api/internal/repository"
 	"products-api/internal/services"
+	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+var (
+	errClientID         = apperr.New(apperr.BadRequest, "client_id_not_allowed", "id is assigned by the server; use ?import=true to keep client-supplied ids")
+	errPatchContentType = apperr.New(apperr.UnsupportedMediaType, "unsupported_media_type", "Content-Type must be application/merge-patch+json")
+	errIfMatchRequired  = apperr.New(apperr.PreconditionRequired, "precondition_required", "If-Match is required; send the ETag of the product as last read")
+)
+
 type ProductHandler struct {
 	productService *services.ProductService
+	pricingService *services.PricingService
+}
+
+func NewProductHandler(productService *services.ProductService, pricingService *services.PricingService) *ProductHandler {
+	return &ProductHandler{productService: productService, pricingService: pricingService}
 }
 
-func NewProductHandler(productService *services.ProductService) *ProductHandler {
-	return &ProductHandler{productService: productService}
+// PricedProduct is a product with its price in the currency asked for with
+// ?currency=. In a listing, ConvertedPrice is null for a product that has no
+// price or exchange rate for that currency, or whose converted price is too large.
+type PricedProduct struct {
+	models.Product
+	ConvertedPrice *services.PriceQuote `json:"converted_price"`
 }
 
+// PricedProductPage is a ProductPage priced in the currency asked for with ?currency=
+type PricedProductPage struct {
+	Items      []PricedProduct `json:"items"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// CreateProduct creates a product with a server-assigned ID. Client-supplied
+// IDs are rejected unless the request opts into import mode with ?import=true.
 func (h *ProductHandler) CreateProduct(c *fiber.Ctx) error {
-	var product models.Product
-	if err := c.BodyParser(&product); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	var req CreateProductRequest
+	if err := c.BodyParser(&req); err != nil {
+		return apperr.ErrInvalidBody
+	}
+	if err := req.Validate(); err != nil {
+		return err
 	}
 
-	err := h.productService.Create(c.Context(), &product)
+	product := req.Product()
+	var err error
+	if c.QueryBool("import") {
+		err = h.productService.Import(c.Context(), &product)
+	} else if product.ID != "" {
+		return errClientID
+	} else {
+		err = h.productService.Create(c.Context(), &product)
+	}
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create product"})
+		return err
 	}
 
+	setETag(c, &product)
 	return c.Status(fiber.StatusCreated).JSON(product)
 }
 
+// ImportProducts stores a batch of products, keeping their IDs, in a single
+// transaction. Invalid products fail the whole batch with 422 and the fields
+// of every invalid product.
+func (h *ProductHandler) ImportProducts(c *fiber.Ctx) error {
+	var req ImportProductsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return apperr.ErrInvalidBody
+	}
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	products := make([]models.Product, len(req.Products))
+	for i, item := range req.Products {
+		products[i] = item.Product()
+	}
+	if err := h.productService.ImportProducts(c.Context(), products); err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"imported": len(products), "products": products})
+}
+
+// GetProducts lists products a page at a time. Pass the returned next_cursor
+// back as ?cursor= to fetch the following page. With ?currency= every product
+// also carries its price in that currency, looked up on ?price_list=.
 func (h *ProductHandler) GetProducts(c *fiber.Ctx) error {
-	products, err := h.productService.GetProducts(c.Context())
+	query, err := parseProductQuery(c)
+	if err != nil {
+		return err
+	}
+	currency, err := priceCurrency(c)
+	if err != nil {
+		return err
+	}
+
+	page, err := h.productService.GetProducts(c.Context(), query)
+	if err != nil {
+		return err
+	}
+	if currency == "" {
+		return c.JSON(page)
+	}
+	items, err := h.priceProducts(c, page.Items, currency)
+	if err != nil {
+		return err
+	}
+	return c.JSON(PricedProductPage{Items: items, NextCursor: page.NextCursor})
+}
+
+// GetProduct returns a product with its version as ETag, or 304 Not Modified
+// when If-None-Match names that version. With ?currency= it also carries its
+// price in that currency, looked up on ?price_list=; such a response has no
+// ETag and is always sent in full, since exchange rates change without the
+// product changing.
+func (h *ProductHandler) GetProduct(c *fiber.Ctx) error {
+	currency, err := priceCurrency(c)
+	if err != nil {
+		return err
+	}
+	product, err := h.productService.GetProductByID(c.Context(), c.Params("id"), c.QueryBool("include_deleted"))
+	if err != nil {
+		return err
+	}
+	if currency == "" {
+		setETag(c, product)
+		if ifNoneMatch(c, product) {
+			return c.SendStatus(fiber.StatusNotModified)
+		}
+		return c.JSON(product)
+	}
+	quote, err := h.pricingService.QuoteProduct(c.Context(), *product, currency, c.Query("price_list"))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve products"})
+		return err
 	}
-	return c.JSON(products)
+	return c.JSON(PricedProduct{Product: *product, ConvertedPrice: &quote})
+}
+
+// priceProducts quotes products in currency on the request's price list,
+// leaving the quote of a product that cannot be priced nil
+func (h *ProductHandler) priceProducts(c *fiber.Ctx, products []models.Product, currency money.Currency) ([]PricedProduct, error) {
+	quotes, err := h.pricingService.Quote(c.Context(), products, currency, c.Query("price_list"))
+	if err != nil {
+		return nil, err
+	}
+	priced := make([]PricedProduct, len(products))
+	for i, product := range products {
+		priced[i] = PricedProduct{Product: product, ConvertedPrice: quotes[i]}
+	}
+	return priced, nil
+}
+
+// priceCurrency reads ?currency=, which is empty when prices are wanted as stored
+func priceCurrency(c *fiber.Ctx) (money.Currency, error) {
+	if c.Query("currency") == "" {
+		return "", nil
+	}
+	return currencyParam(c.Query("currency"), "currency")
+}
+
+// UpdateProduct replaces a product with the request body (PUT semantics). The
+// request must send the product's ETag as If-Match.
+func (h *ProductHandler) UpdateProduct(c *fiber.Ctx) error {
+	precondition, err := ifMatch(c)
+	if err != nil {
+		return err
+	}
+	var req UpdateProductRequest
+	if err := c.BodyParser(&req); err != nil {
+		return apperr.ErrInvalidBody
+	}
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	product := req.Product()
+	if err := h.productService.UpdateProduct(c.Context(), c.Params("id"), precondition, &product); err != nil {
+		return err
+	}
+	setETag(c, &product)
+	return c.JSON(product)
+}
+
+// PatchProduct partially updates a product from a JSON merge patch (RFC 7386)
+// body. The request must send the product's ETag as If-Match.
+func (h *ProductHandler) PatchProduct(c *fiber.Ctx) error {
+	contentType := strings.ToLower(c.Get(fiber.HeaderContentType))
+	if !strings.HasPrefix(contentType, "application/merge-patch+json") && !strings.HasPrefix(contentType, fiber.MIMEApplicationJSON) {
+		return errPatchContentType
+	}
+	precondition, err := ifMatch(c)
+	if err != nil {
+		return err
+	}
+
+	product, err := h.productService.PatchProduct(c.Context(), c.Params("id"), precondition, c.Body())
+	if err != nil {
+		return err
+	}
+	setETag(c, product)
+	return c.JSON(product)
+}
+
+// DeleteProduct soft deletes a product. The request must send the product's
+// ETag as If-Match.
+func (h *ProductHandler) DeleteProduct(c *fiber.Ctx) error {
+	precondition, err := ifMatch(c)
+	if err != nil {
+		return err
+	}
+	if err := h.productService.DeleteProduct(c.Context(), c.Params("id"), precondition); err != nil {
+		return err
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RestoreProduct undoes a soft delete.
+func (h *ProductHandler) RestoreProduct(c *fiber.Ctx) error {
+	product, err := h.productService.RestoreProduct(c.Context(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+	setETag(c, product)
+	return c.JSON(product)
+}
+
+// DecrementStock atomically removes units from a product's stock, answering
+// 409 Conflict when not enough units are left.
+func (h *ProductHandler) DecrementStock(c *fiber.Ctx) error {
+	var body struct {
+		Quantity int `json:"quantity"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return apperr.ErrInvalidBody
+	}
+
+	product, err := h.productService.UpdateProductCount(c.Context(), c.Params("id"), body.Quantity)
+	if err != nil {
+		return err
+	}
+	setETag(c, product)
+	return c.JSON(product)
+}
+
+// setETag sends the version of product as its entity tag
+func setETag(c *fiber.Ctx, product *models.Product) {
+	c.Set(fiber.HeaderETag, strconv.Quote(strconv.Itoa(product.Version)))
+}
+
+// ifNoneMatch reports whether the If-None-Match header names the current
+// version of product. Tags are compared weakly, so W/"<version>" matches too.
+func ifNoneMatch(c *fiber.Ctx, product *models.Product) bool {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfNoneMatch))
+	if header == "*" {
+		return true
+	}
+	current := strconv.Itoa(product.Version)
+	for _, tag := range strings.Split(header, ",") {
+		value, err := strconv.Unquote(strings.TrimPrefix(strings.TrimSpace(tag), "W/"))
+		if err == nil && value == current {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatch reads the If-Match header a write must send. Only strong tags of
+// the form "<version>" can match; any other tag is kept out of the
+// precondition, so a request sending nothing else fails it.
+func ifMatch(c *fiber.Ctx) (services.Precondition, error) {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if header == "" {
+		return services.Precondition{}, errIfMatchRequired
+	}
+	if header == "*" {
+		return services.Precondition{Any: true}, nil
+	}
+	var precondition services.Precondition
+	for _, tag := range strings.Split(header, ",") {
+		value, err := strconv.Unquote(strings.TrimSpace(tag))
+		if err != nil {
+			continue
+		}
+		if version, err := strconv.Atoi(value); err == nil {
+			precondition.Versions = append(precondition.Versions, version)
+		}
+	}
+	return precondition, nil
+}
+
+// parseProductQuery reads pagination, sorting and filter parameters from the
+// query string. min_price and max_price are exact amounts in price_currency,
+// which also limits the listing to products priced in that currency. Sorting
+// by price requires price_currency too.
+func parseProductQuery(c *fiber.Ctx) (repository.ProductQuery, error) {
+	query := repository.ProductQuery{
+		Cursor:         c.Query("cursor"),
+		SellerID:       c.Query("seller_id"),
+		NamePrefix:     c.Query("name_prefix"),
+		InStock:        c.QueryBool("in_stock"),
+		IncludeDeleted: c.QueryBool("include_deleted"),
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > repository.MaxPageSize {
+			return query, apperr.InvalidParameter(fmt.Sprintf("limit must be between 1 and %d", repository.MaxPageSize))
+		}
+		query.Limit = n
+	}
+
+	sort, err := repository.ParseProductSort(c.Query("sort"))
+	if err != nil {
+		return query, apperr.InvalidParameter(err.Error())
+	}
+	query.SortBy = sort
+
+	switch order := strings.ToLower(c.Query("order")); order {
+	case "", "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		return query, apperr.InvalidParameter("order must be asc or desc")
+	}
+
+	if value := c.Query("price_currency"); value != "" {
+		if query.Currency, err = currencyParam(value, "price_currency"); err != nil {
+			return query, err
+		}
+	}
+	for param, target := range map[string]**money.Money{"min_price": &query.MinPrice, "max_price": &query.MaxPrice} {
+		if value := c.Query(param); value != "" {
+			if query.Currency == "" {
+				return query, apperr.InvalidParameter(param + " requires price_currency")
+			}
+			price, err := money.Parse(value, query.Currency)
+			if err != nil || price.Amount < 0 {
+				return query, apperr.InvalidParameter(param + " must be a non-negative amount in price_currency")
+			}
+			*target = &price
+		}
+	}
+	if query.MinPrice != nil && query.MaxPrice != nil && query.MinPrice.Amount > query.MaxPrice.Amount {
+		return query, apperr.InvalidParameter("min_price must not exceed max_price")
+	}
+	if query.SortBy == repository.SortByPrice && query.Currency == "" {
+		return query, apperr.InvalidParameter("sort=price requires price_currency")
+	}
+
+	return query, nil
 }
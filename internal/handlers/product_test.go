@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"products-api/internal/money"
+	"products-api/internal/repository"
+	"products-api/internal/server"
+	"products-api/internal/services"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+var productColumnNames = []string{"id", "name", "price", "seller_id", "quantity", "created_at", "updated_at", "deleted_at", "reserved_quantity", "currency", "version"}
+
+// newProductApp serves the product routes from a ProductHandler backed by sqlmock
+func newProductApp(t *testing.T) (*fiber.App, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+	t.Cleanup(func() {
+		assert.NoError(t, mock.ExpectationsWereMet())
+		db.Close()
+	})
+
+	productService := services.NewProductService(repository.NewProductRepository(db), repository.NewReservationRepository(db),
+		repository.NewInboxRepository(db), repository.NewOutboxRepository(db), repository.NewTransactor(db))
+	pricingService := services.NewPricingService(repository.NewPriceRepository(db), repository.NewExchangeRateRepository(db))
+	handler := NewProductHandler(productService, pricingService)
+
+	app := fiber.New(fiber.Config{ErrorHandler: server.ErrorHandler})
+	app.Get("/products/:id", handler.GetProduct)
+	app.Put("/products/:id", handler.UpdateProduct)
+	app.Patch("/products/:id", handler.PatchProduct)
+	app.Delete("/products/:id", handler.DeleteProduct)
+	return app, mock
+}
+
+// expectLockedProduct expects the product to be locked for a write at version
+func expectLockedProduct(mock sqlmock.Sqlmock, id string, version int) {
+	now := time.Now()
+	mock.ExpectQuery("SELECT .* FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(productColumnNames).AddRow(id, "Product", "9.99", "", 5, now, now, nil, 0, "USD", version))
+}
+
+func TestPatchProductRejectsInvalidResult(t *testing.T) {
+	app, mock := newProductApp(t)
+
+	mock.ExpectBegin()
+	expectLockedProduct(mock, "p1", 1)
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("PATCH", "/products/p1", strings.NewReader(`{"name": "", "quantity": -5}`))
+	req.Header.Set(fiber.HeaderContentType, "application/merge-patch+json")
+	req.Header.Set(fiber.HeaderIfMatch, `"1"`)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+
+	var problem server.Problem
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+	var fields []string
+	for _, field := range problem.Errors {
+		fields = append(fields, field.Field)
+	}
+	assert.Equal(t, []string{"name", "quantity"}, fields)
+}
+
+// expectProduct expects the product to be read at version
+func expectProduct(mock sqlmock.Sqlmock, id string, version int) {
+	now := time.Now()
+	mock.ExpectQuery("SELECT .* FROM products WHERE id = \\$1 AND deleted_at IS NULL$").WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(productColumnNames).AddRow(id, "Product", "9.99", "", 5, now, now, nil, 0, "USD", version))
+}
+
+func TestIfMatch(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: server.ErrorHandler})
+	var parsed services.Precondition
+	app.Put("/products/:id", func(c *fiber.Ctx) error {
+		precondition, err := ifMatch(c)
+		parsed = precondition
+		return err
+	})
+
+	tests := []struct {
+		header       string
+		status       int
+		precondition services.Precondition
+	}{
+		{"", fiber.StatusPreconditionRequired, services.Precondition{}},
+		{"*", fiber.StatusOK, services.Precondition{Any: true}},
+		{`"3"`, fiber.StatusOK, services.Precondition{Versions: []int{3}}},
+		{`"1", "3"`, fiber.StatusOK, services.Precondition{Versions: []int{1, 3}}},
+		{`W/"3", "4"`, fiber.StatusOK, services.Precondition{Versions: []int{4}}},
+		{`garbage, "x"`, fiber.StatusOK, services.Precondition{}},
+	}
+	for _, tt := range tests {
+		parsed = services.Precondition{}
+		req := httptest.NewRequest("PUT", "/products/p1", nil)
+		if tt.header != "" {
+			req.Header.Set(fiber.HeaderIfMatch, tt.header)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s: error making request: %v", tt.header, err)
+		}
+		assert.Equal(t, tt.status, resp.StatusCode, tt.header)
+		assert.Equal(t, tt.precondition, parsed, tt.header)
+	}
+}
+
+func TestDeleteProductWithoutIfMatch(t *testing.T) {
+	app, _ := newProductApp(t)
+
+	resp, err := app.Test(httptest.NewRequest("DELETE", "/products/p1", nil))
+	if err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	assert.Equal(t, fiber.StatusPreconditionRequired, resp.StatusCode)
+}
+
+func TestDeleteProductWithStaleETag(t *testing.T) {
+	tests := []string{`"1"`, "garbage"}
+	for _, header := range tests {
+		app, mock := newProductApp(t)
+
+		mock.ExpectBegin()
+		expectLockedProduct(mock, "p1", 2)
+		mock.ExpectRollback()
+
+		req := httptest.NewRequest("DELETE", "/products/p1", nil)
+		req.Header.Set(fiber.HeaderIfMatch, header)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s: error making request: %v", header, err)
+		}
+		assert.Equal(t, fiber.StatusPreconditionFailed, resp.StatusCode, header)
+	}
+}
+
+func TestGetProductConditional(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+	}{
+		{"current tag", map[string]string{fiber.HeaderIfNoneMatch: `"2"`}, fiber.StatusNotModified},
+		{"weak tag", map[string]string{fiber.HeaderIfNoneMatch: `W/"2"`}, fiber.StatusNotModified},
+		{"tag in list", map[string]string{fiber.HeaderIfNoneMatch: `"1", "2"`}, fiber.StatusNotModified},
+		{"any", map[string]string{fiber.HeaderIfNoneMatch: "*"}, fiber.StatusNotModified},
+		{"stale tag", map[string]string{fiber.HeaderIfNoneMatch: `"1"`}, fiber.StatusOK},
+		{"if-modified-since only", map[string]string{fiber.HeaderIfModifiedSince: time.Now().Add(time.Hour).UTC().Format(time.RFC1123)}, fiber.StatusOK},
+		{"no conditions", nil, fiber.StatusOK},
+	}
+	for _, tt := range tests {
+		app, mock := newProductApp(t)
+		expectProduct(mock, "p1", 2)
+
+		req := httptest.NewRequest("GET", "/products/p1", nil)
+		for name, value := range tt.headers {
+			req.Header.Set(name, value)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s: error making request: %v", tt.name, err)
+		}
+		assert.Equal(t, tt.status, resp.StatusCode, tt.name)
+		assert.Equal(t, `"2"`, resp.Header.Get(fiber.HeaderETag), tt.name)
+	}
+}
+
+func TestGetProductInCurrencyHasNoETag(t *testing.T) {
+	app, mock := newProductApp(t)
+	expectProduct(mock, "p1", 2)
+	mock.ExpectQuery("SELECT .* FROM product_prices").
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "price_list", "currency", "amount", "created_at", "updated_at"}).
+			AddRow("p1", "default", "EUR", 900, time.Now(), time.Now()))
+
+	req := httptest.NewRequest("GET", "/products/p1?currency=EUR", nil)
+	req.Header.Set(fiber.HeaderIfNoneMatch, `"2"`)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get(fiber.HeaderETag))
+}
+
+func TestParseProductQueryPriceFilters(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: server.ErrorHandler})
+	var parsed repository.ProductQuery
+	app.Get("/products", func(c *fiber.Ctx) error {
+		query, err := parseProductQuery(c)
+		parsed = query
+		return err
+	})
+
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{"?price_currency=jpy&min_price=10&max_price=1000", fiber.StatusOK},
+		{"?min_price=10", fiber.StatusBadRequest},
+		{"?price_currency=USD&min_price=0.001", fiber.StatusBadRequest},
+		{"?price_currency=USD&min_price=20&max_price=10", fiber.StatusBadRequest},
+		{"?price_currency=XXX", fiber.StatusBadRequest},
+		{"?sort=price", fiber.StatusBadRequest},
+		{"?sort=price&price_currency=usd", fiber.StatusOK},
+	}
+	for _, tt := range tests {
+		resp, err := app.Test(httptest.NewRequest("GET", "/products"+tt.query, nil))
+		if err != nil {
+			t.Fatalf("%s: error making request: %v", tt.query, err)
+		}
+		assert.Equal(t, tt.status, resp.StatusCode, tt.query)
+	}
+
+	app.Test(httptest.NewRequest("GET", "/products?price_currency=jpy&min_price=10", nil))
+	assert.Equal(t, money.Currency("JPY"), parsed.Currency)
+	assert.Equal(t, money.New(10, "JPY"), *parsed.MinPrice)
+}
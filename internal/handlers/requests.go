@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"products-api/internal/models"
+	"products-api/internal/money"
+	"products-api/internal/validation"
+	"strings"
+	"time"
+)
+
+// PriceRequest is a price as sent by clients, such as
+// {"amount": "19.99", "currency": "EUR"}. The amount may be a string or a
+// number and is kept as written, so one with too many decimal places is
+// reported instead of rounded. The currency defaults to money.DefaultCurrency.
+type PriceRequest struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency money.Currency  `json:"currency"`
+}
+
+func (r PriceRequest) currency() money.Currency {
+	if r.Currency == "" {
+		return money.DefaultCurrency
+	}
+	return r.Currency
+}
+
+// Money returns the requested price. An amount that cannot be read is left
+// zero; Validate reports why.
+func (r PriceRequest) Money() money.Money {
+	amount, err := money.AmountText(r.Amount)
+	if err != nil {
+		return money.Money{Currency: r.currency()}
+	}
+	price, err := money.Parse(amount, r.currency())
+	if err != nil {
+		return money.Money{Currency: r.currency()}
+	}
+	return price
+}
+
+// Validate checks that the amount is a decimal number with no more decimal
+// places than the currency has. The currency itself is checked with the product.
+func (r PriceRequest) Validate() error {
+	return validation.Validate(validation.Field("amount", r.Amount, validAmount(r.currency())))
+}
+
+func validAmount(currency money.Currency) validation.Rule[json.RawMessage] {
+	return func(raw json.RawMessage) string {
+		if len(raw) == 0 || string(raw) == "null" {
+			return "is required"
+		}
+		if !currency.Valid() {
+			return ""
+		}
+		amount, err := money.AmountText(raw)
+		if err == nil {
+			_, err = money.Parse(amount, currency)
+		}
+		if err != nil {
+			return err.Error()
+		}
+		return ""
+	}
+}
+
+// CreateProductRequest is the body of POST /products. It only holds the
+// fields a client may set; server-managed fields such as timestamps and
+// reserved stock are not part of it, so values sent for them are ignored.
+type CreateProductRequest struct {
+	// ID is only accepted in import mode
+	ID       string       `json:"id"`
+	Name     string       `json:"name"`
+	Price    PriceRequest `json:"price"`
+	SellerID string       `json:"seller_id"`
+	Quantity int          `json:"quantity"`
+}
+
+// Product returns the product the request describes
+func (r CreateProductRequest) Product() models.Product {
+	return models.Product{
+		BaseModel: models.BaseModel{ID: r.ID},
+		Name:      r.Name,
+		Price:     r.Price.Money(),
+		SellerID:  r.SellerID,
+		Quantity:  r.Quantity,
+	}
+}
+
+// Validate applies the product rules to the request
+func (r CreateProductRequest) Validate() error {
+	product := r.Product()
+	return validation.Validate(validation.Merge(product.Validate()), validation.Nested("price", r.Price.Validate()))
+}
+
+// UpdateProductRequest is the body of PUT /products/:id
+type UpdateProductRequest struct {
+	Name     string       `json:"name"`
+	Price    PriceRequest `json:"price"`
+	SellerID string       `json:"seller_id"`
+	Quantity int          `json:"quantity"`
+}
+
+// Product returns the product the request describes
+func (r UpdateProductRequest) Product() models.Product {
+	return models.Product{
+		Name:     r.Name,
+		Price:    r.Price.Money(),
+		SellerID: r.SellerID,
+		Quantity: r.Quantity,
+	}
+}
+
+// Validate applies the product rules to the request
+func (r UpdateProductRequest) Validate() error {
+	product := r.Product()
+	return validation.Validate(validation.Merge(product.Validate()), validation.Nested("price", r.Price.Validate()))
+}
+
+// ImportProductsRequest is the body of POST /products/import
+type ImportProductsRequest struct {
+	Products []CreateProductRequest `json:"products"`
+}
+
+// Validate applies the product rules to every product in the batch
+func (r ImportProductsRequest) Validate() error {
+	checks := make([]validation.Check, len(r.Products))
+	for i, item := range r.Products {
+		checks[i] = validation.Nested(fmt.Sprintf("products[%d]", i), item.Validate())
+	}
+	return validation.Validate(checks...)
+}
+
+// SetPriceRequest is the body of PUT /products/:id/prices/:currency
+type SetPriceRequest struct {
+	Amount json.RawMessage `json:"amount"`
+	// PriceList defaults to models.DefaultPriceList
+	PriceList string `json:"price_list"`
+}
+
+// ExchangeRateRequest is the body of POST /exchange-rates
+type ExchangeRateRequest struct {
+	Base  money.Currency `json:"base"`
+	Quote money.Currency `json:"quote"`
+	// Rate may be a string or a number and is kept as written
+	Rate json.RawMessage `json:"rate"`
+	// EffectiveAt defaults to now
+	EffectiveAt time.Time `json:"effective_at"`
+}
+
+// ExchangeRate returns the rate the request describes
+func (r ExchangeRateRequest) ExchangeRate() models.ExchangeRate {
+	rate, _ := money.AmountText(r.Rate)
+	return models.ExchangeRate{
+		Base:        money.Currency(strings.ToUpper(string(r.Base))),
+		Quote:       money.Currency(strings.ToUpper(string(r.Quote))),
+		Rate:        rate,
+		EffectiveAt: r.EffectiveAt,
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"products-api/internal/apperr"
+	"products-api/internal/services"
+	"products-api/internal/validation"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ReservationHandler struct {
+	reservationService *services.ReservationService
+}
+
+func NewReservationHandler(reservationService *services.ReservationService) *ReservationHandler {
+	return &ReservationHandler{reservationService: reservationService}
+}
+
+// CreateReservation reserves units of the product in the path for a cart.
+// ttl_seconds must not exceed the configured maximum reservation TTL.
+func (h *ReservationHandler) CreateReservation(c *fiber.Ctx) error {
+	var body struct {
+		CartID     string `json:"cart_id"`
+		Quantity   int    `json:"quantity"`
+		TTLSeconds int    `json:"ttl_seconds"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return apperr.ErrInvalidBody
+	}
+	// Checked in seconds before converting, so huge values cannot overflow
+	maxSeconds := int(h.reservationService.MaxTTL() / time.Second)
+	if err := validation.Validate(validation.Field("ttl_seconds", body.TTLSeconds, validation.Min(0), validation.Max(maxSeconds))); err != nil {
+		return err
+	}
+
+	ttl := time.Duration(body.TTLSeconds) * time.Second
+	reservation, err := h.reservationService.Reserve(c.Context(), c.Params("id"), body.CartID, body.Quantity, ttl)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusCreated).JSON(reservation)
+}
+
+func (h *ReservationHandler) GetReservation(c *fiber.Ctx) error {
+	reservation, err := h.reservationService.GetReservation(c.Context(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(reservation)
+}
+
+// ConfirmReservation turns a reservation into a sale once payment succeeds.
+func (h *ReservationHandler) ConfirmReservation(c *fiber.Ctx) error {
+	reservation, err := h.reservationService.Confirm(c.Context(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(reservation)
+}
+
+// ReleaseReservation gives reserved units back when a checkout is cancelled.
+func (h *ReservationHandler) ReleaseReservation(c *fiber.Ctx) error {
+	reservation, err := h.reservationService.Release(c.Context(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(reservation)
+}
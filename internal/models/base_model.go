@@ -3,16 +3,18 @@ package models
 import "time"
 
 type BaseModel struct {
-	ID        string    `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	DeletedAt time.Time `json:"deleted_at"`
+	ID        string     `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
+// SetID assigns a new server-generated ID using the configured IDGenerator.
 func (b *BaseModel) SetID() {
-	b.ID = generateUniqueID()
+	b.ID = NewID()
 }
-func generateUniqueID() string {
-	// Dummy implementation for unique ID generation
-	return "UN-" + time.Now().Format("20060102150405")
+
+// IsDeleted reports whether the record has been soft deleted.
+func (b *BaseModel) IsDeleted() bool {
+	return b.DeletedAt != nil
 }
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// IDGenerator produces unique, server-assigned record identifiers.
+type IDGenerator interface {
+	NewID() string
+}
+
+// IDGeneratorFunc adapts an ordinary function to the IDGenerator interface.
+type IDGeneratorFunc func() string
+
+// NewID calls f.
+func (f IDGeneratorFunc) NewID() string {
+	return f()
+}
+
+// UUIDv7Generator generates RFC 9562 version 7 UUIDs. They embed a
+// millisecond timestamp followed by random bits, so they sort by creation
+// time without colliding when several records are created in the same instant.
+type UUIDv7Generator struct{}
+
+// NewID returns a new UUIDv7 string.
+func (UUIDv7Generator) NewID() string {
+	return uuid.Must(uuid.NewV7()).String()
+}
+
+var (
+	idGeneratorMu sync.RWMutex
+	idGenerator   IDGenerator = UUIDv7Generator{}
+)
+
+// SetIDGenerator replaces the generator used by BaseModel.SetID and returns
+// the previous one so tests can restore it.
+func SetIDGenerator(g IDGenerator) IDGenerator {
+	idGeneratorMu.Lock()
+	defer idGeneratorMu.Unlock()
+	previous := idGenerator
+	idGenerator = g
+	return previous
+}
+
+// NewID returns a new identifier from the configured IDGenerator.
+func NewID() string {
+	idGeneratorMu.RLock()
+	defer idGeneratorMu.RUnlock()
+	return idGenerator.NewID()
+}
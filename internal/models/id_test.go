@@ -0,0 +1,42 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetIDUsesConfiguredGenerator(t *testing.T) {
+	next := 0
+	previous := SetIDGenerator(IDGeneratorFunc(func() string {
+		next++
+		return fmt.Sprintf("test-%d", next)
+	}))
+	defer SetIDGenerator(previous)
+
+	var a, b BaseModel
+	a.SetID()
+	b.SetID()
+
+	assert.Equal(t, "test-1", a.ID)
+	assert.Equal(t, "test-2", b.ID)
+}
+
+func TestUUIDv7GeneratorIsUniqueAndSortable(t *testing.T) {
+	var generator UUIDv7Generator
+	seen := make(map[string]bool)
+	previous := ""
+
+	for i := 0; i < 1000; i++ {
+		id := generator.NewID()
+		parsed, err := uuid.Parse(id)
+		assert.NoError(t, err)
+		assert.Equal(t, uuid.Version(7), parsed.Version())
+		assert.False(t, seen[id], "duplicate id %s", id)
+		assert.GreaterOrEqual(t, id[:13], previous, "expected ids to sort by creation time")
+		seen[id] = true
+		previous = id[:13]
+	}
+}
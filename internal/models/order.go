@@ -0,0 +1,16 @@
+package models
+
+import "products-api/internal/money"
+
+// MaxOrderTotalUnits is the most whole currency units the NUMERIC(14,3)
+// total_price column holds
+const MaxOrderTotalUnits = 99_999_999_999
+
+// Order records a purchase of units of one product. TotalPrice is the
+// product's price at the time of the order times Quantity.
+type Order struct {
+	BaseModel
+	ProductID  string      `json:"product_id"`
+	Quantity   int         `json:"quantity"`
+	TotalPrice money.Money `json:"total_price"`
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"fmt"
+	"products-api/internal/money"
+	"products-api/internal/validation"
+	"strings"
+	"time"
+)
+
+// DefaultPriceList is the price list used when none is named
+const DefaultPriceList = "default"
+
+const (
+	// MaxPriceListLength is the longest price list name the product_prices table holds
+	MaxPriceListLength = 100
+	// MaxRateDecimals and MaxRateDigits bound the rates the NUMERIC(20,10) rate column holds
+	MaxRateDecimals = 10
+	MaxRateDigits   = 20
+)
+
+// ProductPrice is the price of a product in one currency on one price list.
+// It takes precedence over converting the product's own price.
+type ProductPrice struct {
+	ProductID string      `json:"product_id"`
+	PriceList string      `json:"price_list"`
+	Price     money.Money `json:"price"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// Validate checks the price list name and the price. It returns validation.Errors.
+func (p *ProductPrice) Validate() error {
+	return validation.Validate(
+		validation.Field("price_list", p.PriceList, validation.Required, validation.MaxLength(MaxPriceListLength)),
+		validation.Field("price", p.Price, validPrice),
+	)
+}
+
+// ExchangeRate is the number of units of Quote one unit of Base buys, from
+// EffectiveAt until a later rate for the same pair takes effect.
+type ExchangeRate struct {
+	Base  money.Currency `json:"base"`
+	Quote money.Currency `json:"quote"`
+	// Rate is a decimal number, kept as text so it is never rounded
+	Rate        string    `json:"rate"`
+	EffectiveAt time.Time `json:"effective_at"`
+}
+
+// Validate checks that the rate is positive and converts between two
+// different supported currencies. It returns validation.Errors.
+func (r *ExchangeRate) Validate() error {
+	return validation.Validate(
+		validation.Field("base", r.Base, validCurrency),
+		validation.Field("quote", r.Quote, validCurrency, func(quote money.Currency) string {
+			if quote == r.Base {
+				return "must differ from base"
+			}
+			return ""
+		}),
+		validation.Field("rate", r.Rate, validation.Required, validRate),
+	)
+}
+
+func validRate(rate string) string {
+	if _, err := money.ParseRate(rate); err != nil {
+		return "must be a positive decimal number"
+	}
+	whole, fraction, _ := strings.Cut(rate, ".")
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > MaxRateDecimals || len(strings.TrimLeft(whole, "0")) > MaxRateDigits-MaxRateDecimals {
+		return fmt.Sprintf("must have at most %d decimal places and %d digits", MaxRateDecimals, MaxRateDigits)
+	}
+	return ""
+}
+
+func validCurrency(currency money.Currency) string {
+	if !currency.Valid() {
+		return fmt.Sprintf("is not a supported currency: %q", currency)
+	}
+	return ""
+}
@@ -1,9 +1,55 @@
 package models
 
+import (
+	"fmt"
+	"products-api/internal/money"
+	"products-api/internal/validation"
+)
+
+const (
+	// MaxNameLength is the longest name, seller ID or ID the products table holds
+	MaxNameLength = 255
+	// MaxPriceUnits is the most whole currency units the NUMERIC(11,3) price column holds
+	MaxPriceUnits = 99_999_999
+)
+
 type Product struct {
 	BaseModel
-	Name     string  `json:"name"`
-	Price    float64 `json:"price"`
-	SellerID string  `json:"seller_id"`
-	Quantity int     `json:"quantity"`
+	Name     string      `json:"name"`
+	Price    money.Money `json:"price"`
+	SellerID string      `json:"seller_id"`
+	Quantity int         `json:"quantity"`
+	// ReservedQuantity is the part of Quantity held by pending reservations.
+	ReservedQuantity int `json:"reserved_quantity"`
+	// AvailableQuantity is Quantity minus ReservedQuantity; it is computed when
+	// the product is loaded and never written.
+	AvailableQuantity int `json:"available_quantity"`
+	// Version is incremented by every write to the product and is served as
+	// its ETag.
+	Version int `json:"version"`
+}
+
+// Validate checks the fields a client can set against the rules every stored
+// product must satisfy. It returns validation.Errors.
+func (p *Product) Validate() error {
+	return validation.Validate(
+		validation.Field("id", p.ID, validation.MaxLength(MaxNameLength)),
+		validation.Field("name", p.Name, validation.Required, validation.MaxLength(MaxNameLength)),
+		validation.Field("price", p.Price, validPrice),
+		validation.Field("seller_id", p.SellerID, validation.MaxLength(MaxNameLength)),
+		validation.Field("quantity", p.Quantity, validation.Min(0)),
+	)
+}
+
+// validPrice requires a supported currency and an amount the price column holds
+func validPrice(price money.Money) string {
+	switch {
+	case !price.Currency.Valid():
+		return fmt.Sprintf("has unknown currency %q", price.Currency)
+	case price.Amount < 0:
+		return "must not be negative"
+	case price.Units() > MaxPriceUnits:
+		return fmt.Sprintf("must be less than %d", MaxPriceUnits+1)
+	}
+	return ""
 }
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"products-api/internal/money"
+	"products-api/internal/validation"
+)
+
+func TestProductValidate(t *testing.T) {
+	valid := Product{Name: "Widget", Price: money.New(1999, "USD"), Quantity: 3}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("expected a valid product, got %v", err)
+	}
+
+	invalid := Product{Name: " ", Price: money.New(-1, "USD"), SellerID: strings.Repeat("s", MaxNameLength+1), Quantity: -1}
+	var fields validation.Errors
+	if err := invalid.Validate(); !errors.As(err, &fields) {
+		t.Fatalf("expected validation.Errors, got %v", err)
+	}
+	got := make(map[string]bool)
+	for _, field := range fields {
+		got[field.Field] = true
+	}
+	for _, want := range []string{"name", "price", "seller_id", "quantity"} {
+		if !got[want] {
+			t.Errorf("expected an error for %s in %v", want, fields)
+		}
+	}
+
+	if err := (&Product{Name: "Widget", Price: money.New((MaxPriceUnits+1)*100, "USD")}).Validate(); err == nil {
+		t.Error("expected prices beyond NUMERIC(11,3) to be rejected")
+	}
+	if err := (&Product{Name: "Widget", Price: money.New(100, "XXX")}).Validate(); err == nil {
+		t.Error("expected an unknown currency to be rejected")
+	}
+}
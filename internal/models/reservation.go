@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// ReservationStatus is the lifecycle state of a stock reservation.
+type ReservationStatus string
+
+const (
+	ReservationPending   ReservationStatus = "pending"
+	ReservationConfirmed ReservationStatus = "confirmed"
+	ReservationReleased  ReservationStatus = "released"
+	ReservationExpired   ReservationStatus = "expired"
+)
+
+// Reservation holds units of a product for a cart until it is confirmed,
+// released, or expires.
+type Reservation struct {
+	BaseModel
+	ProductID string            `json:"product_id"`
+	CartID    string            `json:"cart_id"`
+	Quantity  int               `json:"quantity"`
+	Status    ReservationStatus `json:"status"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
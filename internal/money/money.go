@@ -0,0 +1,239 @@
+// Package money represents amounts of money exactly, as a whole number of
+// minor units (cents for USD) of an ISO 4217 currency. Amounts are parsed from
+// and formatted to decimal strings and are never converted through float64.
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Currency is an ISO 4217 currency code
+type Currency string
+
+// DefaultCurrency is used when a price is given without a currency, and for
+// prices stored before currencies were recorded
+const DefaultCurrency Currency = "USD"
+
+// minorDigits holds the number of minor unit digits of every supported
+// currency. None has more than 3, the scale of the price columns.
+var minorDigits = map[Currency]int{
+	"AUD": 2, "BHD": 3, "BRL": 2, "CAD": 2, "CHF": 2, "CNY": 2, "CZK": 2,
+	"DKK": 2, "EUR": 2, "GBP": 2, "HKD": 2, "HUF": 2, "INR": 2, "JPY": 0,
+	"KRW": 0, "KWD": 3, "MXN": 2, "NOK": 2, "NZD": 2, "PLN": 2, "SEK": 2,
+	"SGD": 2, "USD": 2, "ZAR": 2,
+}
+
+var (
+	// ErrUnknownCurrency is returned for a currency code that is not supported
+	ErrUnknownCurrency = errors.New("unknown currency")
+	// ErrInvalidAmount is returned for an amount that is not a decimal number
+	ErrInvalidAmount = errors.New("amount must be a decimal number")
+	// ErrTooManyDecimals is returned for an amount with more fractional digits
+	// than its currency has minor unit digits
+	ErrTooManyDecimals = errors.New("amount has too many decimal places")
+	// ErrAmountOverflow is returned when arithmetic leaves the range of an int64 amount
+	ErrAmountOverflow = errors.New("amount is too large")
+	// ErrInvalidRate is returned for an exchange rate that is not a positive decimal number
+	ErrInvalidRate = errors.New("rate must be a positive decimal number")
+)
+
+// ratePattern matches the decimal numbers ParseRate accepts
+var ratePattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+// IsInvalid reports whether err is one of the errors returned for an invalid
+// amount or currency
+func IsInvalid(err error) bool {
+	return errors.Is(err, ErrUnknownCurrency) || errors.Is(err, ErrInvalidAmount) || errors.Is(err, ErrTooManyDecimals) ||
+		errors.Is(err, ErrAmountOverflow)
+}
+
+// Valid reports whether c is a supported currency
+func (c Currency) Valid() bool {
+	_, ok := minorDigits[c]
+	return ok
+}
+
+// Digits returns the number of minor unit digits of c
+func (c Currency) Digits() int {
+	return minorDigits[c]
+}
+
+// Money is an amount in minor units of a currency
+type Money struct {
+	Amount   int64
+	Currency Currency
+}
+
+// New returns amount minor units of currency
+func New(amount int64, currency Currency) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Parse reads a decimal amount such as "19.99" in currency. Trailing zeros
+// beyond the currency's minor unit digits are accepted, as databases pad
+// amounts to the column scale; any other extra digit is ErrTooManyDecimals.
+func Parse(amount string, currency Currency) (Money, error) {
+	if !currency.Valid() {
+		return Money{}, fmt.Errorf("%w %q", ErrUnknownCurrency, currency)
+	}
+	digits := currency.Digits()
+
+	negative := strings.HasPrefix(amount, "-")
+	whole, fraction, _ := strings.Cut(strings.TrimPrefix(amount, "-"), ".")
+	if whole == "" || !isDigits(whole) || !isDigits(fraction) || len(whole) > 15 {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+	if strings.Contains(amount, ".") && fraction == "" {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > digits {
+		return Money{}, fmt.Errorf("%w: %s allows %d", ErrTooManyDecimals, currency, digits)
+	}
+
+	minor, err := strconv.ParseInt(whole+fraction+strings.Repeat("0", digits-len(fraction)), 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+	if negative {
+		minor = -minor
+	}
+	return Money{Amount: minor, Currency: currency}, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Units returns the whole currency units of m, truncated toward zero
+func (m Money) Units() int64 {
+	return m.Amount / pow10(m.Currency.Digits())
+}
+
+// Mul returns m times n, or ErrAmountOverflow if the product does not fit in
+// an int64 amount
+func (m Money) Mul(n int64) (Money, error) {
+	product := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(n))
+	if !product.IsInt64() {
+		return Money{}, fmt.Errorf("%w: %s times %d", ErrAmountOverflow, m, n)
+	}
+	return Money{Amount: product.Int64(), Currency: m.Currency}, nil
+}
+
+// Decimal formats the amount with the currency's minor unit digits, such as "19.99"
+func (m Money) Decimal() string {
+	digits := m.Currency.Digits()
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	if digits == 0 {
+		return sign + strconv.FormatInt(amount, 10)
+	}
+	scale := pow10(digits)
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/scale, digits, amount%scale)
+}
+
+// Convert returns m in currency to at rate, the number of units of to one
+// unit of m's currency buys. The result is rounded half away from zero to the
+// minor units of to, or ErrAmountOverflow if it does not fit in an int64 amount.
+func (m Money) Convert(to Currency, rate *big.Rat) (Money, error) {
+	value := new(big.Rat).SetFrac(big.NewInt(m.Amount), big.NewInt(pow10(m.Currency.Digits())))
+	value.Mul(value, rate)
+	value.Mul(value, new(big.Rat).SetInt64(pow10(to.Digits())))
+
+	quotient, remainder := new(big.Int).QuoRem(value.Num(), value.Denom(), new(big.Int))
+	// Num carries the sign, so a non-zero remainder has the sign of value
+	if remainder.Mul(remainder.Abs(remainder), big.NewInt(2)).Cmp(value.Denom()) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(value.Sign())))
+	}
+	if !quotient.IsInt64() {
+		return Money{}, fmt.Errorf("%w: %s at %s %s", ErrAmountOverflow, m, rate.RatString(), to)
+	}
+	return Money{Amount: quotient.Int64(), Currency: to}, nil
+}
+
+// ParseRate reads an exchange rate such as "0.9215"
+func ParseRate(rate string) (*big.Rat, error) {
+	value, ok := new(big.Rat).SetString(rate)
+	if !ok || !ratePattern.MatchString(rate) || value.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRate, rate)
+	}
+	return value, nil
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + string(m.Currency)
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for range n {
+		p *= 10
+	}
+	return p
+}
+
+// jsonMoney is the JSON form of Money. The amount is a decimal string so
+// clients do not have to round trip it through floating point.
+type jsonMoney struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency Currency        `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	amount, err := json.Marshal(m.Decimal())
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonMoney{Amount: amount, Currency: m.Currency})
+}
+
+// UnmarshalJSON reads {"amount": "19.99", "currency": "USD"}. The amount may
+// also be a JSON number, which is read digit for digit rather than as a float.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return fmt.Errorf("%w: expected an object with amount and currency", ErrInvalidAmount)
+	}
+	var v jsonMoney
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	amount, err := AmountText(v.Amount)
+	if err != nil {
+		return err
+	}
+	parsed, err := Parse(amount, v.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// AmountText returns the digits of a JSON amount given either as a string or
+// as a number
+func AmountText(raw json.RawMessage) (string, error) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+	var number json.Number
+	if err := json.Unmarshal(raw, &number); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidAmount, raw)
+	}
+	return number.String(), nil
+}
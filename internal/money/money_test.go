@@ -0,0 +1,129 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency Currency
+		want     int64
+		err      error
+	}{
+		{"19.99", "USD", 1999, nil},
+		{"0.1", "USD", 10, nil},
+		{"7", "EUR", 700, nil},
+		{"-2.50", "USD", -250, nil},
+		{"9.990", "USD", 999, nil},
+		{"500", "JPY", 500, nil},
+		{"1.234", "KWD", 1234, nil},
+		{"0.001", "USD", 0, ErrTooManyDecimals},
+		{"5.5", "JPY", 0, ErrTooManyDecimals},
+		{"1e3", "USD", 0, ErrInvalidAmount},
+		{"1.", "USD", 0, ErrInvalidAmount},
+		{".5", "USD", 0, ErrInvalidAmount},
+		{"", "USD", 0, ErrInvalidAmount},
+		{"1", "XXX", 0, ErrUnknownCurrency},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.amount, tt.currency)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Parse(%q, %s) error = %v; want %v", tt.amount, tt.currency, err, tt.err)
+			continue
+		}
+		if err == nil && got.Amount != tt.want {
+			t.Errorf("Parse(%q, %s) = %d; want %d", tt.amount, tt.currency, got.Amount, tt.want)
+		}
+	}
+}
+
+func TestSumsAreExact(t *testing.T) {
+	a, _ := Parse("0.1", "USD")
+	b, _ := Parse("0.2", "USD")
+	if sum := New(a.Amount+b.Amount, "USD"); sum.Decimal() != "0.30" {
+		t.Errorf("0.1 + 0.2 = %s; want 0.30", sum.Decimal())
+	}
+	if total, err := New(999, "USD").Mul(3); err != nil || total.Decimal() != "29.97" {
+		t.Errorf("9.99 * 3 = %s (%v); want 29.97", total.Decimal(), err)
+	}
+	if _, err := New(9_999_999_999, "USD").Mul(1 << 40); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("expected ErrAmountOverflow; got %v", err)
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	tests := map[string]Money{
+		"19.99": New(1999, "USD"),
+		"0.05":  New(5, "EUR"),
+		"-1.50": New(-150, "GBP"),
+		"500":   New(500, "JPY"),
+		"1.005": New(1005, "KWD"),
+	}
+	for want, m := range tests {
+		if got := m.Decimal(); got != want {
+			t.Errorf("%d %s formatted as %q; want %q", m.Amount, m.Currency, got, want)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	data, err := json.Marshal(New(1999, "USD"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != `{"amount":"19.99","currency":"USD"}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+
+	var m Money
+	if err := json.Unmarshal([]byte(`{"amount":0.3,"currency":"EUR"}`), &m); err != nil || m != New(30, "EUR") {
+		t.Errorf("expected 0.30 EUR; got %v (%v)", m, err)
+	}
+	if err := json.Unmarshal([]byte(`{"amount":"1.999","currency":"USD"}`), &m); !errors.Is(err, ErrTooManyDecimals) {
+		t.Errorf("expected ErrTooManyDecimals; got %v", err)
+	}
+	if err := json.Unmarshal([]byte(`12.5`), &m); !IsInvalid(err) {
+		t.Errorf("expected a bare number to be rejected; got %v", err)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		from Money
+		to   Currency
+		rate string
+		want Money
+	}{
+		{New(1999, "USD"), "EUR", "0.92", New(1839, "EUR")},
+		{New(1000, "USD"), "EUR", "0.92345", New(923, "EUR")},
+		{New(1, "USD"), "EUR", "0.5", New(1, "EUR")},
+		{New(-1, "USD"), "EUR", "0.5", New(-1, "EUR")},
+		{New(1999, "USD"), "JPY", "151.37", New(3026, "JPY")},
+		{New(500, "JPY"), "USD", "0.0066", New(330, "USD")},
+		{New(1000, "USD"), "KWD", "0.3075", New(3075, "KWD")},
+	}
+	for _, tt := range tests {
+		rate, err := ParseRate(tt.rate)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, err := tt.from.Convert(tt.to, rate); err != nil || got != tt.want {
+			t.Errorf("%s at %s = %s, %v; want %s", tt.from, tt.rate, got, err, tt.want)
+		}
+	}
+
+	rate, _ := ParseRate("9999999999")
+	if _, err := New(math.MaxInt64/100, "USD").Convert("EUR", rate); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("Convert of an amount too large error = %v; want ErrAmountOverflow", err)
+	}
+
+	for _, invalid := range []string{"0", "-1", "1/3", "1e2", ""} {
+		if _, err := ParseRate(invalid); !errors.Is(err, ErrInvalidRate) {
+			t.Errorf("ParseRate(%q) error = %v; want ErrInvalidRate", invalid, err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"products-api/internal/apperr"
+	"products-api/internal/models"
+	"products-api/internal/money"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const exchangeRateColumns = "base_currency, quote_currency, rate, effective_at"
+
+// ErrExchangeRateNotFound is returned when no rate between two currencies is
+// in effect. It wraps sql.ErrNoRows.
+var ErrExchangeRateNotFound = apperr.Wrap(apperr.NotFound, "exchange_rate_not_found", "exchange rate not found", sql.ErrNoRows)
+
+// ExchangeRateRepository stores exchange rates with the time they take effect.
+type ExchangeRateRepository struct {
+	db DBTX
+}
+
+func NewExchangeRateRepository(db DBTX) *ExchangeRateRepository {
+	return &ExchangeRateRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *ExchangeRateRepository) WithTx(tx *sql.Tx) *ExchangeRateRepository {
+	return &ExchangeRateRepository{db: tx}
+}
+
+func scanExchangeRate(row rowScanner, rate *models.ExchangeRate) error {
+	if err := row.Scan(&rate.Base, &rate.Quote, &rate.Rate, &rate.EffectiveAt); err != nil {
+		return notFound(err, ErrExchangeRateNotFound)
+	}
+	// NUMERIC pads the rate to the column scale
+	if strings.Contains(rate.Rate, ".") {
+		rate.Rate = strings.TrimRight(strings.TrimRight(rate.Rate, "0"), ".")
+	}
+	return nil
+}
+
+// Set stores a rate, replacing any rate for the same pair taking effect at the same time.
+func (r *ExchangeRateRepository) Set(ctx context.Context, rate *models.ExchangeRate) error {
+	query := `INSERT INTO exchange_rates (base_currency, quote_currency, rate, effective_at, created_at)
+	          VALUES ($1, $2, $3, $4, NOW())
+	          ON CONFLICT (base_currency, quote_currency, effective_at) DO UPDATE SET rate = EXCLUDED.rate
+	          RETURNING ` + exchangeRateColumns
+	return scanExchangeRate(r.db.QueryRowContext(ctx, query, rate.Base, rate.Quote, rate.Rate, rate.EffectiveAt), rate)
+}
+
+// List returns the stored rates, optionally only those from base or to quote,
+// newest first within each pair.
+func (r *ExchangeRateRepository) List(ctx context.Context, base, quote money.Currency) ([]models.ExchangeRate, error) {
+	var (
+		conditions []string
+		args       []any
+	)
+	if base != "" {
+		args = append(args, base)
+		conditions = append(conditions, "base_currency = $"+strconv.Itoa(len(args)))
+	}
+	if quote != "" {
+		args = append(args, quote)
+		conditions = append(conditions, "quote_currency = $"+strconv.Itoa(len(args)))
+	}
+	query := "SELECT " + exchangeRateColumns + " FROM exchange_rates"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY base_currency, quote_currency, effective_at DESC"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []models.ExchangeRate{}
+	for rows.Next() {
+		var rate models.ExchangeRate
+		if err := scanExchangeRate(rows, &rate); err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
+
+// Effective returns the latest rate between from and to that took effect by
+// at. A rate stored for the opposite direction is returned as it is, so the
+// caller must invert it when its Base is to. It returns ErrExchangeRateNotFound
+// if neither direction has a rate in effect.
+func (r *ExchangeRateRepository) Effective(ctx context.Context, from, to money.Currency, at time.Time) (*models.ExchangeRate, error) {
+	query := "SELECT " + exchangeRateColumns + ` FROM exchange_rates
+	          WHERE ((base_currency = $1 AND quote_currency = $2) OR (base_currency = $2 AND quote_currency = $1))
+	          AND effective_at <= $3
+	          ORDER BY effective_at DESC, base_currency = $1 DESC LIMIT 1`
+	var rate models.ExchangeRate
+	if err := scanExchangeRate(r.db.QueryRowContext(ctx, query, from, to, at), &rate); err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
@@ -0,0 +1,5 @@
+package repository
+
+// StartPostgres lets integration tests of the services built on the
+// repositories share the throwaway database
+var StartPostgres = startPostgres
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// InboxRepository is the ledger of consumed messages used to make message
+// handling idempotent.
+type InboxRepository struct {
+	db DBTX
+}
+
+func NewInboxRepository(db DBTX) *InboxRepository {
+	return &InboxRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *InboxRepository) WithTx(tx *sql.Tx) *InboxRepository {
+	return &InboxRepository{db: tx}
+}
+
+// MarkProcessed records that a message has been handled. It returns false
+// without error when the message ID or order ID is already in the ledger, in
+// which case the caller should treat the message as a duplicate. Called inside
+// the transaction that applies the message, a concurrent duplicate blocks on
+// the unique index until the first delivery commits or rolls back.
+func (r *InboxRepository) MarkProcessed(ctx context.Context, messageID, orderID string) (bool, error) {
+	query := `INSERT INTO processed_messages (message_id, order_id, processed_at)
+	          VALUES ($1, NULLIF($2, ''), NOW()) ON CONFLICT DO NOTHING`
+	result, err := r.db.ExecContext(ctx, query, messageID, orderID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// DeleteProcessedBefore removes ledger entries recorded before the given time
+// and returns the number of rows removed.
+func (r *InboxRepository) DeleteProcessedBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM processed_messages WHERE processed_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInboxMarkProcessed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewInboxRepository(db)
+
+	mock.ExpectExec("INSERT INTO processed_messages .* ON CONFLICT DO NOTHING").WithArgs("m1", "o1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO processed_messages .* ON CONFLICT DO NOTHING").WithArgs("m1", "o1").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	first, err := repo.MarkProcessed(context.Background(), "m1", "o1")
+	assert.NoError(t, err)
+	assert.True(t, first, "expected the first delivery to be recorded")
+
+	again, err := repo.MarkProcessed(context.Background(), "m1", "o1")
+	assert.NoError(t, err)
+	assert.False(t, again, "expected a redelivery to be reported as a duplicate")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInboxDeleteProcessedBefore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewInboxRepository(db)
+
+	before := time.Now().Add(-time.Hour)
+	mock.ExpectExec("DELETE FROM processed_messages WHERE processed_at < \\$1").WithArgs(before).WillReturnResult(sqlmock.NewResult(0, 4))
+
+	deleted, err := repo.DeleteProcessedBefore(context.Background(), before)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"products-api/internal/models"
+	"products-api/internal/money"
+	"strconv"
+	"strings"
+)
+
+const orderColumns = "id, product_id, quantity, total_price, currency, created_at, updated_at"
+
+var (
+	ORDER_CREATE_QUERY = `INSERT INTO orders (id, product_id, quantity, total_price, currency, created_at, updated_at)
+	                      VALUES ($1, $2, $3, $4, $5, NOW(), NOW()) RETURNING ` + orderColumns
+)
+
+// OrderQuery describes one page of an order listing, newest first.
+type OrderQuery struct {
+	Limit int
+	// Cursor is the ID of the last order on the previous page
+	Cursor    string
+	ProductID string
+}
+
+// OrderPage is one page of orders and the cursor for the page after it.
+type OrderPage struct {
+	Items      []models.Order `json:"items"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+type OrderRepository struct {
+	db DBTX
+}
+
+func NewOrderRepository(db DBTX) *OrderRepository {
+	return &OrderRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *OrderRepository) WithTx(tx *sql.Tx) *OrderRepository {
+	return &OrderRepository{db: tx}
+}
+
+func scanOrder(row rowScanner, order *models.Order) error {
+	var total string
+	var currency money.Currency
+	err := row.Scan(&order.ID, &order.ProductID, &order.Quantity, &total, &currency, &order.CreatedAt, &order.UpdatedAt)
+	if err != nil {
+		return notFound(err, ErrOrderNotFound)
+	}
+	if order.TotalPrice, err = money.Parse(total, currency); err != nil {
+		return fmt.Errorf("order %s: %w", order.ID, err)
+	}
+	return nil
+}
+
+// Create inserts an order and fills in the timestamps assigned by the database.
+func (r *OrderRepository) Create(ctx context.Context, order *models.Order) error {
+	row := r.db.QueryRowContext(ctx, ORDER_CREATE_QUERY, order.ID, order.ProductID, order.Quantity, order.TotalPrice.Decimal(), order.TotalPrice.Currency)
+	err := scanOrder(row, order)
+	if isUniqueViolation(err) {
+		return ErrDuplicateID
+	}
+	return err
+}
+
+// GetByID returns an order or ErrOrderNotFound.
+func (r *OrderRepository) GetByID(ctx context.Context, id string) (*models.Order, error) {
+	query := "SELECT " + orderColumns + " FROM orders WHERE id = $1"
+	var order models.Order
+	if err := scanOrder(r.db.QueryRowContext(ctx, query, id), &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// List returns one page of orders, newest first. Order IDs are UUIDv7, which
+// sort by creation time, so the ID alone serves as the keyset cursor.
+func (r *OrderRepository) List(ctx context.Context, q OrderQuery) (*OrderPage, error) {
+	limit := q.Limit
+	switch {
+	case limit <= 0:
+		limit = DefaultPageSize
+	case limit > MaxPageSize:
+		limit = MaxPageSize
+	}
+
+	var (
+		conditions []string
+		args       []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if q.ProductID != "" {
+		conditions = append(conditions, "product_id = "+arg(q.ProductID))
+	}
+	if q.Cursor != "" {
+		conditions = append(conditions, "id < "+arg(q.Cursor))
+	}
+
+	query := "SELECT " + orderColumns + " FROM orders"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	// One extra row tells whether there is a next page
+	query += " ORDER BY id DESC LIMIT " + arg(limit+1)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &OrderPage{Items: []models.Order{}}
+	for rows.Next() {
+		var order models.Order
+		if err := scanOrder(rows, &order); err != nil {
+			return nil, err
+		}
+		page.Items = append(page.Items, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(page.Items) > limit {
+		page.Items = page.Items[:limit]
+		page.NextCursor = page.Items[limit-1].ID
+	}
+	return page, nil
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var orderColumnNames = []string{"id", "product_id", "quantity", "total_price", "currency", "created_at", "updated_at"}
+
+func TestListOrdersPagesByID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewOrderRepository(db)
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT .* FROM orders WHERE product_id = \\$1 AND id < \\$2 ORDER BY id DESC LIMIT \\$3").
+		WithArgs("p1", "o9", 3).
+		WillReturnRows(sqlmock.NewRows(orderColumnNames).
+			AddRow("o8", "p1", 1, "5.000", "USD", now, now).
+			AddRow("o7", "p1", 2, "10.000", "USD", now, now).
+			AddRow("o6", "p1", 1, "5.000", "USD", now, now))
+
+	page, err := repo.List(context.Background(), OrderQuery{Limit: 2, Cursor: "o9", ProductID: "p1"})
+	assert.NoError(t, err)
+	assert.Len(t, page.Items, 2)
+	assert.Equal(t, "o7", page.NextCursor)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListOrdersLastPage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewOrderRepository(db)
+
+	mock.ExpectQuery("SELECT .* FROM orders ORDER BY id DESC LIMIT \\$1").WithArgs(DefaultPageSize + 1).
+		WillReturnRows(sqlmock.NewRows(orderColumnNames))
+
+	page, err := repo.List(context.Background(), OrderQuery{})
+	assert.NoError(t, err)
+	assert.Empty(t, page.Items)
+	assert.Empty(t, page.NextCursor)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"products-api/internal/models"
+	"time"
+)
+
+// OutboxRepository stores domain events until the relay publishes them.
+type OutboxRepository struct {
+	db DBTX
+}
+
+func NewOutboxRepository(db DBTX) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *OutboxRepository) WithTx(tx *sql.Tx) *OutboxRepository {
+	return &OutboxRepository{db: tx}
+}
+
+// Add appends a message to the outbox. Call it on a repository bound to the
+// transaction that makes the change the event describes.
+func (r *OutboxRepository) Add(ctx context.Context, msg *models.OutboxMessage) error {
+	query := `INSERT INTO outbox (event_id, aggregate_id, event_type, payload, created_at, next_attempt_at)
+	          VALUES ($1, $2, $3, $4, NOW(), NOW()) RETURNING id, created_at`
+	return r.db.QueryRowContext(ctx, query, msg.EventID, msg.AggregateID, msg.EventType, []byte(msg.Payload)).Scan(&msg.ID, &msg.CreatedAt)
+}
+
+// ClaimPending locks up to limit unsent messages that are due for delivery.
+// Only the oldest unsent message of each aggregate is returned, so events for
+// one product are always published in order, and rows locked by another relay
+// are skipped. It must be called on a repository bound to a transaction.
+func (r *OutboxRepository) ClaimPending(ctx context.Context, now time.Time, limit int) ([]models.OutboxMessage, error) {
+	query := `SELECT o.id, o.event_id, o.aggregate_id, o.event_type, o.payload, o.attempts, o.created_at
+	          FROM outbox o
+	          WHERE o.sent_at IS NULL AND o.next_attempt_at <= $1
+	            AND NOT EXISTS (
+	              SELECT 1 FROM outbox earlier
+	              WHERE earlier.aggregate_id = o.aggregate_id AND earlier.sent_at IS NULL AND earlier.id < o.id
+	            )
+	          ORDER BY o.id LIMIT $2 FOR UPDATE SKIP LOCKED`
+	rows, err := r.db.QueryContext(ctx, query, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []models.OutboxMessage
+	for rows.Next() {
+		var msg models.OutboxMessage
+		var payload []byte
+		if err := rows.Scan(&msg.ID, &msg.EventID, &msg.AggregateID, &msg.EventType, &payload, &msg.Attempts, &msg.CreatedAt); err != nil {
+			return nil, err
+		}
+		msg.Payload = payload
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+// MarkSent records that a message was published.
+func (r *OutboxRepository) MarkSent(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE outbox SET sent_at = NOW(), last_error = NULL WHERE id = $1", id)
+	return err
+}
+
+// MarkFailed records a failed publish attempt and when to try again.
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id int64, cause string, nextAttempt time.Time) error {
+	query := "UPDATE outbox SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2 WHERE id = $3"
+	_, err := r.db.ExecContext(ctx, query, cause, nextAttempt, id)
+	return err
+}
+
+// DeleteSentBefore removes messages published before the given time and
+// returns the number of rows removed.
+func (r *OutboxRepository) DeleteSentBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM outbox WHERE sent_at IS NOT NULL AND sent_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
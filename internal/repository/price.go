@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"products-api/internal/apperr"
+	"products-api/internal/models"
+	"products-api/internal/money"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const productPriceColumns = "product_id, price_list, currency, amount, created_at, updated_at"
+
+// ErrPriceNotFound is returned for a price list entry that does not exist. It
+// wraps sql.ErrNoRows.
+var ErrPriceNotFound = apperr.Wrap(apperr.NotFound, "price_not_found", "price not found", sql.ErrNoRows)
+
+// PriceRepository stores the prices of products in other currencies and on price lists.
+type PriceRepository struct {
+	db DBTX
+}
+
+func NewPriceRepository(db DBTX) *PriceRepository {
+	return &PriceRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *PriceRepository) WithTx(tx *sql.Tx) *PriceRepository {
+	return &PriceRepository{db: tx}
+}
+
+// isForeignKeyViolation reports whether err is a Postgres foreign_key_violation (SQLSTATE 23503).
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503"
+}
+
+func scanProductPrice(row rowScanner, p *models.ProductPrice) error {
+	var amount string
+	var currency money.Currency
+	if err := row.Scan(&p.ProductID, &p.PriceList, &currency, &amount, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return notFound(err, ErrPriceNotFound)
+	}
+	var err error
+	if p.Price, err = money.Parse(amount, currency); err != nil {
+		return fmt.Errorf("price of product %s: %w", p.ProductID, err)
+	}
+	return nil
+}
+
+// Set creates or replaces the price of a product in one currency on one price
+// list. It returns ErrProductNotFound if the product does not exist.
+func (r *PriceRepository) Set(ctx context.Context, price *models.ProductPrice) error {
+	query := `INSERT INTO product_prices (product_id, price_list, currency, amount, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, NOW(), NOW())
+	          ON CONFLICT (product_id, price_list, currency) DO UPDATE SET amount = EXCLUDED.amount, updated_at = NOW()
+	          RETURNING ` + productPriceColumns
+	row := r.db.QueryRowContext(ctx, query, price.ProductID, price.PriceList, price.Price.Currency, price.Price.Decimal())
+	err := scanProductPrice(row, price)
+	if isForeignKeyViolation(err) {
+		return ErrProductNotFound
+	}
+	return err
+}
+
+// ListByProduct returns every price of a product ordered by price list and currency.
+func (r *PriceRepository) ListByProduct(ctx context.Context, productID string) ([]models.ProductPrice, error) {
+	query := "SELECT " + productPriceColumns + " FROM product_prices WHERE product_id = $1 ORDER BY price_list, currency"
+	return r.list(ctx, query, productID)
+}
+
+// Find returns the prices in currency on any of priceLists of any of productIDs.
+func (r *PriceRepository) Find(ctx context.Context, productIDs, priceLists []string, currency money.Currency) ([]models.ProductPrice, error) {
+	if len(productIDs) == 0 || len(priceLists) == 0 {
+		return []models.ProductPrice{}, nil
+	}
+	args := []any{currency}
+	in := func(values []string) string {
+		placeholders := make([]string, len(values))
+		for i, v := range values {
+			args = append(args, v)
+			placeholders[i] = "$" + strconv.Itoa(len(args))
+		}
+		return strings.Join(placeholders, ", ")
+	}
+	query := "SELECT " + productPriceColumns + " FROM product_prices WHERE currency = $1" +
+		" AND product_id IN (" + in(productIDs) + ") AND price_list IN (" + in(priceLists) + ")"
+	return r.list(ctx, query, args...)
+}
+
+func (r *PriceRepository) list(ctx context.Context, query string, args ...any) ([]models.ProductPrice, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := []models.ProductPrice{}
+	for rows.Next() {
+		var price models.ProductPrice
+		if err := scanProductPrice(rows, &price); err != nil {
+			return nil, err
+		}
+		prices = append(prices, price)
+	}
+	return prices, rows.Err()
+}
+
+// Delete removes the price of a product in currency on priceList. It returns
+// ErrPriceNotFound if there is none.
+func (r *PriceRepository) Delete(ctx context.Context, productID, priceList string, currency money.Currency) error {
+	query := "DELETE FROM product_prices WHERE product_id = $1 AND price_list = $2 AND currency = $3"
+	result, err := r.db.ExecContext(ctx, query, productID, priceList, currency)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrPriceNotFound
+	}
+	return nil
+}
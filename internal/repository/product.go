@@ -3,76 +3,299 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"products-api/internal/apperr"
 	"products-api/internal/models"
+	"products-api/internal/money"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	// COUNT_UPDATE_QUERY decrements stock in a single conditional statement so
+	// concurrent orders cannot lose updates, drive quantity below zero or
+	// consume units held by pending reservations.
+	COUNT_UPDATE_QUERY = `UPDATE products SET quantity = quantity - $1, version = version + 1, updated_at = NOW()
+	                      WHERE id = $2 AND quantity - reserved_quantity >= $1 AND deleted_at IS NULL RETURNING ` + productColumns
 )
 
 var (
-	ORDER_CREATE_QUERY = `INSERT INTO orders (id, product_id, quantity, total_price, created_at, updated_at)
-	                      VALUES ($1, $2, $3, $4, NOW(), NOW()) RETURNING id, product_id, quantity, total_price, created_at, updated_at`
-	COUNT_UPDATE_QUERY = `UPDATE products SET quantity = $1, updated_at = NOW() WHERE id = $2`
+	// ErrDuplicateID is returned when inserting a record whose ID is already taken.
+	ErrDuplicateID = apperr.New(apperr.Conflict, "duplicate_id", "a record with this id already exists")
+	// ErrInsufficientStock is returned when a product does not have enough
+	// quantity left to satisfy a decrement.
+	ErrInsufficientStock = apperr.New(apperr.InsufficientStock, "insufficient_stock", "insufficient stock")
+
+	// The not-found errors wrap sql.ErrNoRows, so callers may match either.
+	ErrProductNotFound     = apperr.Wrap(apperr.NotFound, "product_not_found", "product not found", sql.ErrNoRows)
+	ErrReservationNotFound = apperr.Wrap(apperr.NotFound, "reservation_not_found", "reservation not found", sql.ErrNoRows)
+	ErrOrderNotFound       = apperr.Wrap(apperr.NotFound, "order_not_found", "order not found", sql.ErrNoRows)
 )
 
+// productColumns is the column list scanned by scanProduct.
+const productColumns = "id, name, price, seller_id, quantity, created_at, updated_at, deleted_at, reserved_quantity, currency, version"
+
 type ProductRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
-func NewProductRepository(db *sql.DB) *ProductRepository {
+func NewProductRepository(db DBTX) *ProductRepository {
 	return &ProductRepository{db: db}
 }
 
-func (r *ProductRepository) GetAll(ctx context.Context) ([]models.Product, error) {
-	query := "SELECT id, name, price, seller_id, quantity, created_at, updated_at FROM products"
-	rows, err := r.db.QueryContext(ctx, query)
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *ProductRepository) WithTx(tx *sql.Tx) *ProductRepository {
+	return &ProductRepository{db: tx}
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// isUniqueViolation reports whether err is a Postgres unique_violation (SQLSTATE 23505).
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// notFound replaces sql.ErrNoRows with the not-found error of the record type.
+func notFound(err, notFoundErr error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFoundErr
+	}
+	return err
+}
+
+func scanProduct(row rowScanner, p *models.Product) error {
+	var price string
+	var currency money.Currency
+	if err := row.Scan(&p.ID, &p.Name, &price, &p.SellerID, &p.Quantity, &p.CreatedAt, &p.UpdatedAt, &p.DeletedAt, &p.ReservedQuantity, &currency, &p.Version); err != nil {
+		return notFound(err, ErrProductNotFound)
+	}
+	var err error
+	if p.Price, err = money.Parse(price, currency); err != nil {
+		return fmt.Errorf("product %s: %w", p.ID, err)
+	}
+	p.AvailableQuantity = p.Quantity - p.ReservedQuantity
+	return nil
+}
+
+// GetAll returns one page of products matching q using keyset pagination.
+func (r *ProductRepository) GetAll(ctx context.Context, q ProductQuery) (*ProductPage, error) {
+	query, args, err := q.build()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var products []models.Product
+	page := &ProductPage{Items: []models.Product{}}
 	for rows.Next() {
 		var p models.Product
-		err := rows.Scan(&p.ID, &p.Name, &p.Price, &p.SellerID, &p.Quantity, &p.CreatedAt, &p.UpdatedAt)
-		if err != nil {
+		if err := scanProduct(rows, &p); err != nil {
 			return nil, err
 		}
-		products = append(products, p)
+		page.Items = append(page.Items, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
-	return products, rows.Err()
+
+	if limit := q.limit(); len(page.Items) > limit {
+		page.Items = page.Items[:limit]
+		page.NextCursor = q.nextCursor(page.Items[limit-1])
+	}
+	return page, nil
 }
 
+// Create inserts a product and fills in the timestamps assigned by the database.
+// It returns ErrDuplicateID if a product with the same ID already exists.
+// The price column holds every valid price exactly, so it is not read back.
 func (r *ProductRepository) Create(ctx context.Context, req *models.Product) error {
-	query := `INSERT INTO products (id, name, price, seller_id, quantity, created_at, updated_at)
-	          VALUES ($1, $2, $3, $4, $5, NOW(), NOW()) RETURNING id, name, price, seller_id, quantity, created_at, updated_at`
+	query := `INSERT INTO products (id, name, price, currency, seller_id, quantity, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW()) RETURNING id, name, seller_id, quantity, created_at, updated_at, version`
+	err := r.db.QueryRowContext(ctx, query, req.ID, req.Name, req.Price.Decimal(), req.Price.Currency, req.SellerID, req.Quantity).Scan(
+		&req.ID, &req.Name, &req.SellerID, &req.Quantity, &req.CreatedAt, &req.UpdatedAt, &req.Version)
+	if isUniqueViolation(err) {
+		return ErrDuplicateID
+	}
+	return err
+}
+
+// Update overwrites the mutable fields of an existing product, increments its
+// version and refreshes product with the stored row. It returns ErrProductNotFound if the product does not exist.
+func (r *ProductRepository) Update(ctx context.Context, product *models.Product) error {
+	query := `UPDATE products SET name = $1, price = $2, currency = $3, seller_id = $4, quantity = $5, version = version + 1, updated_at = NOW()
+	          WHERE id = $6 AND deleted_at IS NULL RETURNING ` + productColumns
+	row := r.db.QueryRowContext(ctx, query, product.Name, product.Price.Decimal(), product.Price.Currency, product.SellerID, product.Quantity, product.ID)
+	return scanProduct(row, product)
+}
+
+// UpdateProductCount atomically subtracts sold from a product's quantity and
+// returns the updated product. It returns ErrInsufficientStock if fewer than
+// sold unreserved units remain, and ErrProductNotFound if the product does not exist.
+func (r *ProductRepository) UpdateProductCount(ctx context.Context, id string, sold int) (*models.Product, error) {
 	var p models.Product
-	err := r.db.QueryRowContext(ctx, query, req.ID, req.Name, req.Price, req.SellerID, req.Quantity).Scan(
-		&p.ID, &p.Name, &p.Price, &p.SellerID, &p.Quantity, &p.CreatedAt, &p.UpdatedAt)
+	err := scanProduct(r.db.QueryRowContext(ctx, COUNT_UPDATE_QUERY, sold, id), &p)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, r.missingOrInsufficient(ctx, id)
+	}
 	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Reserve holds quantity units of a product's available stock. It returns
+// ErrInsufficientStock if fewer units are available and ErrProductNotFound if the
+// product does not exist.
+func (r *ProductRepository) Reserve(ctx context.Context, id string, quantity int) (*models.Product, error) {
+	query := `UPDATE products SET reserved_quantity = reserved_quantity + $1, version = version + 1, updated_at = NOW()
+	          WHERE id = $2 AND quantity - reserved_quantity >= $1 AND deleted_at IS NULL RETURNING ` + productColumns
+	var p models.Product
+	err := scanProduct(r.db.QueryRowContext(ctx, query, quantity, id), &p)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, r.missingOrInsufficient(ctx, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// LockProducts locks the rows of the given products, deleted or not, in ID
+// order until the surrounding transaction ends. A product row is always locked
+// before the rows of its reservations, so callers that go on to lock
+// reservations cannot deadlock with one another. It must be called on a
+// repository bound to a transaction.
+func (r *ProductRepository) LockProducts(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := make([]any, len(ids))
+	placeholders := make([]string, len(ids))
+	for i, id := range ids {
+		args[i] = id
+		placeholders[i] = "$" + strconv.Itoa(i+1)
+	}
+	query := "SELECT id FROM products WHERE id IN (" + strings.Join(placeholders, ", ") + ") ORDER BY id FOR UPDATE"
+	_, err := r.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// ReleaseReserved returns quantity reserved units to a product's available stock.
+func (r *ProductRepository) ReleaseReserved(ctx context.Context, id string, quantity int) error {
+	query := `UPDATE products SET reserved_quantity = GREATEST(reserved_quantity - $1, 0), version = version + 1, updated_at = NOW() WHERE id = $2`
+	_, err := r.db.ExecContext(ctx, query, quantity, id)
+	return err
+}
+
+// CommitReserved turns quantity reserved units into a sale by removing them
+// from both the reserved and on-hand quantities. It returns ErrProductNotFound
+// if the product has been deleted since the units were reserved.
+func (r *ProductRepository) CommitReserved(ctx context.Context, id string, quantity int) (*models.Product, error) {
+	query := `UPDATE products SET quantity = quantity - $1, reserved_quantity = reserved_quantity - $1, version = version + 1, updated_at = NOW()
+	          WHERE id = $2 AND reserved_quantity >= $1 AND quantity >= $1 AND deleted_at IS NULL RETURNING ` + productColumns
+	var p models.Product
+	err := scanProduct(r.db.QueryRowContext(ctx, query, quantity, id), &p)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, r.missingOrInsufficient(ctx, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// missingOrInsufficient explains why a conditional stock update matched no rows.
+func (r *ProductRepository) missingOrInsufficient(ctx context.Context, id string) error {
+	var exists bool
+	query := "SELECT EXISTS (SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NULL)"
+	if err := r.db.QueryRowContext(ctx, query, id).Scan(&exists); err != nil {
 		return err
 	}
-	return nil
+	if exists {
+		return ErrInsufficientStock
+	}
+	return ErrProductNotFound
 }
 
-func (r *ProductRepository) UpdateProductCount(ctx context.Context, product *models.Product, sold int) error {
-	product.Quantity -= sold
-	query := `UPDATE products SET quantity = $1, updated_at = NOW() WHERE id = $2`
-	_, err := r.db.ExecContext(ctx, query, product.Quantity, product.ID)
+// DeleteProduct soft deletes a product by stamping deleted_at and clears its
+// reserved quantity, as a deleted product holds no reservations.
+// It returns ErrProductNotFound if the product does not exist or is already deleted.
+func (r *ProductRepository) DeleteProduct(ctx context.Context, id string) error {
+	query := "UPDATE products SET deleted_at = NOW(), reserved_quantity = 0, version = version + 1, updated_at = NOW() WHERE id = $1 AND deleted_at IS NULL"
+	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrProductNotFound
+	}
 	return nil
 }
 
-func (r *ProductRepository) DeleteProduct(ctx context.Context, id string) error {
-	query := "DELETE FROM products WHERE id = $1"
-	_, err := r.db.ExecContext(ctx, query, id)
-	return err
+// RestoreProduct clears deleted_at on a soft-deleted product.
+// It returns ErrProductNotFound if the product does not exist or is not deleted.
+func (r *ProductRepository) RestoreProduct(ctx context.Context, id string) (*models.Product, error) {
+	query := "UPDATE products SET deleted_at = NULL, version = version + 1, updated_at = NOW() WHERE id = $1 AND deleted_at IS NOT NULL RETURNING " + productColumns
+	var p models.Product
+	if err := scanProduct(r.db.QueryRowContext(ctx, query, id), &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// PurgeDeleted permanently removes products soft deleted before the given time
+// and returns the number of rows removed. Products that have been ordered are
+// kept, soft deleted, so order history keeps pointing at them.
+func (r *ProductRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM products p WHERE p.deleted_at IS NOT NULL AND p.deleted_at < $1
+	          AND NOT EXISTS (SELECT 1 FROM orders o WHERE o.product_id = p.id)`
+	result, err := r.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
+// GetProductByID returns a product that has not been soft deleted.
 func (r *ProductRepository) GetProductByID(ctx context.Context, id string) (*models.Product, error) {
-	query := "SELECT id, name, price, seller_id, quantity, created_at, updated_at FROM products WHERE id = $1"
+	return r.FindProductByID(ctx, id, false)
+}
+
+// GetProductByIDForUpdate returns a product that has not been soft deleted and
+// locks its row until the surrounding transaction ends. It must be called on a
+// repository bound to a transaction.
+func (r *ProductRepository) GetProductByIDForUpdate(ctx context.Context, id string) (*models.Product, error) {
+	query := "SELECT " + productColumns + " FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE"
 	var p models.Product
-	err := r.db.QueryRowContext(ctx, query, id).Scan(&p.ID, &p.Name, &p.Price, &p.SellerID, &p.Quantity, &p.CreatedAt, &p.UpdatedAt)
-	if err != nil {
+	if err := scanProduct(r.db.QueryRowContext(ctx, query, id), &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// FindProductByID returns a product, optionally including soft-deleted rows.
+func (r *ProductRepository) FindProductByID(ctx context.Context, id string, includeDeleted bool) (*models.Product, error) {
+	query := "SELECT " + productColumns + " FROM products WHERE id = $1"
+	if !includeDeleted {
+		query += " AND deleted_at IS NULL"
+	}
+	var p models.Product
+	if err := scanProduct(r.db.QueryRowContext(ctx, query, id), &p); err != nil {
 		return nil, err
 	}
 	return &p, nil
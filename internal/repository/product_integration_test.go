@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"products-api/internal/database"
+	"products-api/internal/models"
+	"products-api/internal/money"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// startPostgres runs a throwaway Postgres container with the schema migrated.
+// The test is skipped when no container runtime is available.
+func startPostgres(t *testing.T) *sql.DB {
+	t.Helper()
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	ctx := context.Background()
+	container, err := postgres.Run(ctx,
+		"postgres:latest",
+		postgres.WithDatabase("database"),
+		postgres.WithUsername("user"),
+		postgres.WithPassword("password"),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(30*time.Second)),
+	)
+	if err != nil {
+		t.Fatalf("could not start postgres container: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := container.Terminate(context.Background()); err != nil {
+			t.Errorf("could not teardown postgres container: %v", err)
+		}
+	})
+
+	connStr, err := container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatalf("could not get connection string: %v", err)
+	}
+	db, err := sql.Open("pgx", connStr)
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := database.RunMigrations(db); err != nil {
+		t.Fatalf("could not run migrations: %v", err)
+	}
+	return db
+}
+
+func TestIntegrationConcurrentUpdateProductCount(t *testing.T) {
+	db := startPostgres(t)
+	repo := NewProductRepository(db)
+	ctx := context.Background()
+
+	const stock, buyers = 50, 200
+	product := models.Product{Name: "Limited Edition", Price: money.New(1000, "USD"), Quantity: stock}
+	product.SetID()
+	if err := repo.Create(ctx, &product); err != nil {
+		t.Fatalf("could not create product: %v", err)
+	}
+
+	var (
+		wg           sync.WaitGroup
+		mu           sync.Mutex
+		sold, denied int
+	)
+	for i := 0; i < buyers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.UpdateProductCount(ctx, product.ID, 1)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				sold++
+			case errors.Is(err, ErrInsufficientStock):
+				denied++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if sold != stock {
+		t.Errorf("expected exactly %d successful decrements; got %d", stock, sold)
+	}
+	if denied != buyers-stock {
+		t.Errorf("expected %d ErrInsufficientStock results; got %d", buyers-stock, denied)
+	}
+
+	stored, err := repo.GetProductByID(ctx, product.ID)
+	if err != nil {
+		t.Fatalf("could not reload product: %v", err)
+	}
+	if stored.Quantity != 0 {
+		t.Errorf("expected quantity to end at 0; got %d", stored.Quantity)
+	}
+}
+
+func TestIntegrationPurgeDeletedKeepsOrderedProducts(t *testing.T) {
+	db := startPostgres(t)
+	products := NewProductRepository(db)
+	orders := NewOrderRepository(db)
+	ctx := context.Background()
+
+	var ordered, unordered models.Product
+	for _, p := range []*models.Product{&ordered, &unordered} {
+		*p = models.Product{Name: "Discontinued", Price: money.New(500, "USD"), Quantity: 1}
+		p.SetID()
+		if err := products.Create(ctx, p); err != nil {
+			t.Fatalf("could not create product: %v", err)
+		}
+	}
+	order := models.Order{ProductID: ordered.ID, Quantity: 1, TotalPrice: money.New(500, "USD")}
+	order.SetID()
+	if err := orders.Create(ctx, &order); err != nil {
+		t.Fatalf("could not create order: %v", err)
+	}
+	for _, p := range []models.Product{ordered, unordered} {
+		if err := products.DeleteProduct(ctx, p.ID); err != nil {
+			t.Fatalf("could not delete product: %v", err)
+		}
+	}
+
+	purged, err := products.PurgeDeleted(ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("purge failed: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("expected only the unordered product to be purged; purged %d", purged)
+	}
+	if _, err := products.FindProductByID(ctx, ordered.ID, true); err != nil {
+		t.Errorf("expected the ordered product to be kept: %v", err)
+	}
+	if _, err := products.FindProductByID(ctx, unordered.ID, true); !errors.Is(err, ErrProductNotFound) {
+		t.Errorf("expected the unordered product to be gone; got %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"products-api/internal/apperr"
+	"products-api/internal/models"
+	"products-api/internal/money"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultPageSize is used when a ProductQuery has no limit.
+	DefaultPageSize = 50
+	// MaxPageSize caps the number of products returned in one page.
+	MaxPageSize = 200
+)
+
+// decimalPattern matches the prices encoded in cursors
+var decimalPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded or
+// was issued for a different sort order.
+var ErrInvalidCursor = apperr.New(apperr.BadRequest, "invalid_cursor", "invalid cursor")
+
+// ProductSort is a column products can be ordered by.
+type ProductSort string
+
+const (
+	SortByCreatedAt ProductSort = "created_at"
+	SortByName      ProductSort = "name"
+	SortByPrice     ProductSort = "price"
+	SortByQuantity  ProductSort = "quantity"
+)
+
+// ParseProductSort validates a sort column name, defaulting to created_at.
+func ParseProductSort(value string) (ProductSort, error) {
+	switch sort := ProductSort(value); sort {
+	case "":
+		return SortByCreatedAt, nil
+	case SortByCreatedAt, SortByName, SortByPrice, SortByQuantity:
+		return sort, nil
+	default:
+		return "", fmt.Errorf("unsupported sort %q", value)
+	}
+}
+
+// ProductQuery describes one page of a product listing.
+type ProductQuery struct {
+	Limit      int
+	Cursor     string
+	SortBy     ProductSort
+	Descending bool
+	SellerID   string
+	// Currency keeps products priced in that currency. Prices in different
+	// currencies cannot be compared, so MinPrice, MaxPrice and sorting by
+	// price require it.
+	Currency       money.Currency
+	MinPrice       *money.Money
+	MaxPrice       *money.Money
+	InStock        bool
+	NamePrefix     string
+	IncludeDeleted bool
+}
+
+// ProductPage is one page of products and the cursor for the page after it.
+type ProductPage struct {
+	Items      []models.Product `json:"items"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// productCursor is the decoded form of the opaque cursor handed to clients.
+// It records the sort key of the last row on a page plus its id as a tie-breaker,
+// and for a price sort the currency the price is in.
+type productCursor struct {
+	Sort     ProductSort    `json:"s"`
+	Desc     bool           `json:"d"`
+	Value    string         `json:"v"`
+	Currency money.Currency `json:"c,omitempty"`
+	ID       string         `json:"id"`
+}
+
+func (q ProductQuery) limit() int {
+	switch {
+	case q.Limit <= 0:
+		return DefaultPageSize
+	case q.Limit > MaxPageSize:
+		return MaxPageSize
+	default:
+		return q.Limit
+	}
+}
+
+func (q ProductQuery) sort() ProductSort {
+	if q.SortBy == "" {
+		return SortByCreatedAt
+	}
+	return q.SortBy
+}
+
+// build returns the SELECT statement and arguments for the query. One row more
+// than the page size is requested so callers can tell whether a next page exists.
+func (q ProductQuery) build() (string, []any, error) {
+	var (
+		conditions []string
+		args       []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if !q.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	if q.SellerID != "" {
+		conditions = append(conditions, "seller_id = "+arg(q.SellerID))
+	}
+	if q.Currency != "" {
+		conditions = append(conditions, "currency = "+arg(q.Currency))
+	}
+	for _, bound := range []struct {
+		price      *money.Money
+		comparison string
+	}{{q.MinPrice, ">="}, {q.MaxPrice, "<="}} {
+		if bound.price == nil {
+			continue
+		}
+		if bound.price.Currency != q.Currency {
+			return "", nil, apperr.InvalidParameter("price filters must be in the currency filtered on")
+		}
+		// Passed on as text so the NUMERIC comparison stays exact
+		conditions = append(conditions, "price "+bound.comparison+" "+arg(bound.price.Decimal()))
+	}
+	if q.InStock {
+		conditions = append(conditions, "quantity > 0")
+	}
+	if q.NamePrefix != "" {
+		conditions = append(conditions, "name LIKE "+arg(escapeLike(q.NamePrefix)+"%"))
+	}
+
+	if q.sort() == SortByPrice && q.Currency == "" {
+		return "", nil, apperr.InvalidParameter("sorting by price requires price_currency")
+	}
+
+	column := string(q.sort())
+	direction, comparison := "ASC", ">"
+	if q.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if q.Cursor != "" {
+		cursor, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return "", nil, err
+		}
+		if cursor.Sort != q.sort() || cursor.Desc != q.Descending || (cursor.Sort == SortByPrice && cursor.Currency != q.Currency) {
+			return "", nil, ErrInvalidCursor
+		}
+		value, err := cursor.sortValue()
+		if err != nil {
+			return "", nil, err
+		}
+		v, id := arg(value), arg(cursor.ID)
+		// The leading single-column bound lets the planner range-scan the
+		// index on the sort column before applying the row comparison.
+		conditions = append(conditions, fmt.Sprintf("%s %s= %s AND (%s, id) %s (%s, %s)", column, comparison, v, column, comparison, v, id))
+	}
+
+	query := "SELECT " + productColumns + " FROM products"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT %s", column, direction, direction, arg(q.limit()+1))
+	return query, args, nil
+}
+
+// nextCursor encodes the position after p for the query's sort order.
+func (q ProductQuery) nextCursor(p models.Product) string {
+	cursor := productCursor{Sort: q.sort(), Desc: q.Descending, ID: p.ID}
+	switch cursor.Sort {
+	case SortByName:
+		cursor.Value = p.Name
+	case SortByPrice:
+		cursor.Value, cursor.Currency = p.Price.Decimal(), p.Price.Currency
+	case SortByQuantity:
+		cursor.Value = strconv.Itoa(p.Quantity)
+	default:
+		cursor.Value = p.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(encoded string) (productCursor, error) {
+	var cursor productCursor
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" {
+		return cursor, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// sortValue converts the cursor's sort key back to the column's Go type.
+func (c productCursor) sortValue() (any, error) {
+	switch c.Sort {
+	case SortByName:
+		return c.Value, nil
+	case SortByPrice:
+		// Passed on as text so the NUMERIC comparison stays exact
+		if !decimalPattern.MatchString(c.Value) {
+			return nil, ErrInvalidCursor
+		}
+		return c.Value, nil
+	case SortByQuantity:
+		v, err := strconv.Atoi(c.Value)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return v, nil
+	case SortByCreatedAt:
+		v, err := time.Parse(time.RFC3339Nano, c.Value)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return v, nil
+	default:
+		return nil, ErrInvalidCursor
+	}
+}
+
+// escapeLike escapes LIKE wildcards so user input is matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
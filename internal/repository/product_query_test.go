@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"products-api/internal/money"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductQueryBuildFilters(t *testing.T) {
+	minPrice, maxPrice := money.New(500, "EUR"), money.New(5000, "EUR")
+	query, args, err := ProductQuery{
+		Limit:      10,
+		SortBy:     SortByPrice,
+		Descending: true,
+		Currency:   "EUR",
+		MinPrice:   &minPrice,
+		MaxPrice:   &maxPrice,
+		InStock:    true,
+		NamePrefix: "50%_off",
+	}.build()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT "+productColumns+" FROM products WHERE deleted_at IS NULL AND currency = $1 AND price >= $2 AND price <= $3"+
+		" AND quantity > 0 AND name LIKE $4 ORDER BY price DESC, id DESC LIMIT $5", query)
+	assert.Equal(t, []any{money.Currency("EUR"), "5.00", "50.00", `50\%\_off%`, 11}, args)
+}
+
+func TestProductQueryPriceFilterNeedsItsCurrency(t *testing.T) {
+	minPrice := money.New(1000, "JPY")
+	_, _, err := ProductQuery{Currency: "USD", MinPrice: &minPrice}.build()
+	assert.Error(t, err)
+	_, _, err = ProductQuery{MinPrice: &minPrice}.build()
+	assert.Error(t, err)
+}
+
+func TestProductQueryPriceSortNeedsACurrency(t *testing.T) {
+	_, _, err := ProductQuery{SortBy: SortByPrice}.build()
+	assert.Error(t, err)
+
+	// A cursor from a listing in another currency does not carry over
+	product := MockProduct()
+	usd := ProductQuery{SortBy: SortByPrice, Currency: product.Price.Currency}
+	_, _, err = usd.build()
+	assert.NoError(t, err)
+	_, _, err = ProductQuery{SortBy: SortByPrice, Currency: "JPY", Cursor: usd.nextCursor(product)}.build()
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestProductQueryCursorRoundTrip(t *testing.T) {
+	product := MockProduct()
+	for _, sort := range []ProductSort{SortByCreatedAt, SortByName, SortByPrice, SortByQuantity} {
+		q := ProductQuery{SortBy: sort, Descending: true}
+		cursor, err := decodeCursor(q.nextCursor(product))
+		assert.NoError(t, err)
+		assert.Equal(t, sort, cursor.Sort)
+		assert.True(t, cursor.Desc)
+		assert.Equal(t, product.ID, cursor.ID)
+
+		_, err = cursor.sortValue()
+		assert.NoError(t, err)
+	}
+}
+
+func TestParseProductSort(t *testing.T) {
+	sort, err := ParseProductSort("")
+	assert.NoError(t, err)
+	assert.Equal(t, SortByCreatedAt, sort)
+
+	_, err = ParseProductSort("seller_id; DROP TABLE products")
+	assert.Error(t, err)
+}
@@ -4,14 +4,18 @@ import (
 	"context"
 	"database/sql"
 	"products-api/internal/models"
+	"products-api/internal/money"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 )
 
+var productColumnNames = []string{"id", "name", "price", "seller_id", "quantity", "created_at", "updated_at", "deleted_at", "reserved_quantity", "currency", "version"}
+
 type ProductRepositoryTestSuite struct {
 	suite.Suite
 	db   *sql.DB
@@ -23,7 +27,7 @@ func MockProduct() models.Product {
 	return models.Product{
 		BaseModel: models.BaseModel{ID: "1"},
 		Name:      "Test Product",
-		Price:     9.99,
+		Price:     money.New(999, "USD"),
 		Quantity:  100,
 	}
 }
@@ -51,8 +55,8 @@ func (suite *ProductRepositoryTestSuite) TearDownTest() {
 func setupProductMock(mock sqlmock.Sqlmock) {
 	fixedTime := time.Now()
 	product := MockProduct()
-	mock.ExpectQuery("INSERT INTO products").WithArgs(product.ID, product.Name, product.Price, product.SellerID, product.Quantity).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "seller_id", "quantity", "created_at", "updated_at"}).AddRow(product.ID, "Test Product", 9.99, "", 0, fixedTime, fixedTime))
-	mock.ExpectQuery("SELECT .* FROM products WHERE .*").WithArgs(product.ID).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "seller_id", "quantity", "created_at", "updated_at"}).AddRow(product.ID, "Test Product", 9.99, "", 100, fixedTime, fixedTime))
+	mock.ExpectQuery("INSERT INTO products").WithArgs(product.ID, product.Name, "9.99", money.Currency("USD"), product.SellerID, product.Quantity).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "seller_id", "quantity", "created_at", "updated_at", "version"}).AddRow(product.ID, "Test Product", "", 100, fixedTime, fixedTime, 1))
+	mock.ExpectQuery("SELECT .* FROM products WHERE .*").WithArgs(product.ID).WillReturnRows(sqlmock.NewRows(productColumnNames).AddRow(product.ID, "Test Product", 9.99, "", 100, fixedTime, fixedTime, nil, 0, "USD", 1))
 }
 
 func (suite *ProductRepositoryTestSuite) TestCreateProduct() {
@@ -71,13 +75,20 @@ func (suite *ProductRepositoryTestSuite) TestCreateProduct() {
 	assert.Equal(suite.T(), product.Quantity, storedProduct.Quantity)
 }
 
+func (suite *ProductRepositoryTestSuite) TestCreateDuplicateProduct() {
+	product := MockProduct()
+	suite.mock.ExpectQuery("INSERT INTO products").WillReturnError(&pgconn.PgError{Code: "23505"})
+
+	err := suite.repo.Create(context.Background(), &product)
+	suite.ErrorIs(err, ErrDuplicateID, "expected unique violations to map to ErrDuplicateID")
+}
+
 func (suite *ProductRepositoryTestSuite) TestUpdateProductCount() {
 	fixedTime := time.Now()
-	product := MockProduct()
-	product.Quantity = 100
-	suite.mock.ExpectExec("UPDATE .*").WithArgs(95, "1").WillReturnResult(sqlmock.NewResult(1, 1))
-	suite.mock.ExpectQuery("SELECT .* FROM products WHERE .*").WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "seller_id", "quantity", "created_at", "updated_at"}).AddRow("1", "Test Product", 9.99, "", 95, fixedTime, fixedTime))
-	err := suite.repo.UpdateProductCount(context.Background(), &product, 5)
+	suite.mock.ExpectQuery("UPDATE products SET quantity = quantity - \\$1.* WHERE id = \\$2 AND quantity - reserved_quantity >= \\$1 .*RETURNING").WithArgs(5, "1").
+		WillReturnRows(sqlmock.NewRows(productColumnNames).AddRow("1", "Test Product", 9.99, "", 95, fixedTime, fixedTime, nil, 0, "USD", 1))
+	suite.mock.ExpectQuery("SELECT .* FROM products WHERE .*").WithArgs("1").WillReturnRows(sqlmock.NewRows(productColumnNames).AddRow("1", "Test Product", 9.99, "", 95, fixedTime, fixedTime, nil, 0, "USD", 1))
+	product, err := suite.repo.UpdateProductCount(context.Background(), "1", 5)
 	suite.NoError(err, "expected no error while updating product count")
 	assert.Equal(suite.T(), 95, product.Quantity, "expected product quantity to be updated correctly")
 
@@ -87,22 +98,41 @@ func (suite *ProductRepositoryTestSuite) TestUpdateProductCount() {
 	assert.Equal(suite.T(), 95, resultProduct.Quantity, "expected retrieved product quantity to be updated")
 }
 
+func (suite *ProductRepositoryTestSuite) TestUpdateProductCountInsufficientStock() {
+	suite.mock.ExpectQuery("UPDATE products SET quantity = quantity - \\$1").WithArgs(500, "1").WillReturnError(sql.ErrNoRows)
+	suite.mock.ExpectQuery("SELECT EXISTS").WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	product, err := suite.repo.UpdateProductCount(context.Background(), "1", 500)
+	suite.ErrorIs(err, ErrInsufficientStock)
+	suite.Nil(product)
+}
+
+func (suite *ProductRepositoryTestSuite) TestUpdateProductCountMissingProduct() {
+	suite.mock.ExpectQuery("UPDATE products SET quantity = quantity - \\$1").WithArgs(1, "404").WillReturnError(sql.ErrNoRows)
+	suite.mock.ExpectQuery("SELECT EXISTS").WithArgs("404").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	_, err := suite.repo.UpdateProductCount(context.Background(), "404", 1)
+	suite.ErrorIs(err, sql.ErrNoRows)
+}
+
 func (suite *ProductRepositoryTestSuite) TestGetAllProducts() {
 	expectedProducts := []models.Product{
-		{BaseModel: models.BaseModel{ID: "1"}, Name: "Product 1", Price: 10.0, SellerID: "seller1", Quantity: 5},
-		{BaseModel: models.BaseModel{ID: "2"}, Name: "Product 2", Price: 20.0, SellerID: "seller2", Quantity: 3},
+		{BaseModel: models.BaseModel{ID: "1"}, Name: "Product 1", Price: money.New(1000, "USD"), SellerID: "seller1", Quantity: 5},
+		{BaseModel: models.BaseModel{ID: "2"}, Name: "Product 2", Price: money.New(2000, "USD"), SellerID: "seller2", Quantity: 3},
 	}
 
-	rows := sqlmock.NewRows([]string{"id", "name", "price", "seller_id", "quantity", "created_at", "updated_at"})
+	rows := sqlmock.NewRows(productColumnNames)
 	for _, p := range expectedProducts {
-		rows.AddRow(p.ID, p.Name, p.Price, p.SellerID, p.Quantity, time.Now(), time.Now())
+		rows.AddRow(p.ID, p.Name, p.Price.Decimal(), p.SellerID, p.Quantity, time.Now(), time.Now(), nil, 0, "USD", 1)
 	}
 
-	suite.mock.ExpectQuery("SELECT .* FROM products$").WillReturnRows(rows)
+	suite.mock.ExpectQuery("SELECT .* FROM products WHERE deleted_at IS NULL ORDER BY created_at ASC, id ASC LIMIT \\$1$").WithArgs(DefaultPageSize + 1).WillReturnRows(rows)
 
-	result, err := suite.repo.GetAll(context.Background())
+	page, err := suite.repo.GetAll(context.Background(), ProductQuery{})
 	suite.NoError(err, "expected no error while getting all products")
+	result := page.Items
 	suite.Len(result, 2, "expected two products")
+	suite.Empty(page.NextCursor, "expected no next cursor on the last page")
 	// Note: Exact match may fail due to time fields, so check key fields
 	for i, p := range result {
 		assert.Equal(suite.T(), expectedProducts[i].ID, p.ID)
@@ -115,18 +145,116 @@ func (suite *ProductRepositoryTestSuite) TestGetAllProducts() {
 }
 
 func (suite *ProductRepositoryTestSuite) TestDeleteProduct() {
-	suite.mock.ExpectExec("DELETE FROM products WHERE .*").WithArgs("1").WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectExec("UPDATE products SET deleted_at = NOW\\(\\).* WHERE id = .* AND deleted_at IS NULL").WithArgs("1").WillReturnResult(sqlmock.NewResult(1, 1))
 
 	err := suite.repo.DeleteProduct(context.Background(), "1")
 	suite.NoError(err, "expected no error while deleting product")
 
-	suite.mock.ExpectQuery("SELECT .* FROM products WHERE .*").WithArgs("1").WillReturnError(sql.ErrNoRows)
+	suite.mock.ExpectQuery("SELECT .* FROM products WHERE .* AND deleted_at IS NULL").WithArgs("1").WillReturnError(sql.ErrNoRows)
 
 	deletedProduct, err := suite.repo.GetProductByID(context.Background(), "1")
 	suite.Error(err, "expected error while retrieving deleted product")
 	suite.Nil(deletedProduct, "expected no product to be returned after deletion")
 }
 
+func (suite *ProductRepositoryTestSuite) TestUpdateProduct() {
+	fixedTime := time.Now()
+	product := MockProduct()
+	product.Name = "Renamed Product"
+	suite.mock.ExpectQuery("UPDATE products SET .* WHERE id = .* RETURNING .*").
+		WithArgs(product.Name, "9.99", money.Currency("USD"), product.SellerID, product.Quantity, product.ID).
+		WillReturnRows(sqlmock.NewRows(productColumnNames).AddRow(product.ID, product.Name, "9.99", "", product.Quantity, fixedTime, fixedTime, nil, 0, "USD", 1))
+
+	err := suite.repo.Update(context.Background(), &product)
+	suite.NoError(err, "expected no error while updating product")
+	assert.Equal(suite.T(), "Renamed Product", product.Name)
+	assert.Equal(suite.T(), fixedTime, product.UpdatedAt)
+}
+
+func (suite *ProductRepositoryTestSuite) TestUpdateMissingProduct() {
+	product := MockProduct()
+	suite.mock.ExpectQuery("UPDATE products SET .*").WillReturnError(sql.ErrNoRows)
+
+	err := suite.repo.Update(context.Background(), &product)
+	suite.ErrorIs(err, sql.ErrNoRows, "expected sql.ErrNoRows for a missing product")
+}
+
+func (suite *ProductRepositoryTestSuite) TestDeleteMissingProduct() {
+	suite.mock.ExpectExec("UPDATE products SET deleted_at = NOW\\(\\)").WithArgs("404").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := suite.repo.DeleteProduct(context.Background(), "404")
+	suite.ErrorIs(err, sql.ErrNoRows, "expected sql.ErrNoRows when nothing was deleted")
+}
+
+func (suite *ProductRepositoryTestSuite) TestGetAllProductsIncludingDeleted() {
+	deletedAt := time.Now()
+	rows := sqlmock.NewRows(productColumnNames).
+		AddRow("1", "Product 1", 10.0, "seller1", 5, time.Now(), time.Now(), nil, 0, "USD", 1).
+		AddRow("2", "Product 2", 20.0, "seller2", 3, time.Now(), time.Now(), deletedAt, 0, "USD", 1)
+	suite.mock.ExpectQuery("SELECT .* FROM products ORDER BY").WillReturnRows(rows)
+
+	page, err := suite.repo.GetAll(context.Background(), ProductQuery{IncludeDeleted: true})
+	suite.NoError(err, "expected no error while getting all products")
+	result := page.Items
+	suite.Len(result, 2, "expected deleted products to be included")
+	suite.False(result[0].IsDeleted())
+	suite.True(result[1].IsDeleted())
+}
+
+func (suite *ProductRepositoryTestSuite) TestGetAllProductsPaginates() {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	rows := sqlmock.NewRows(productColumnNames).
+		AddRow("1", "Product 1", 10.0, "seller1", 5, createdAt, createdAt, nil, 0, "USD", 1).
+		AddRow("2", "Product 2", 20.0, "seller1", 3, createdAt.Add(time.Second), createdAt, nil, 0, "USD", 1).
+		AddRow("3", "Product 3", 30.0, "seller1", 1, createdAt.Add(2*time.Second), createdAt, nil, 0, "USD", 1)
+	suite.mock.ExpectQuery("SELECT .* FROM products WHERE deleted_at IS NULL AND seller_id = \\$1 ORDER BY created_at ASC, id ASC LIMIT \\$2").
+		WithArgs("seller1", 3).WillReturnRows(rows)
+
+	page, err := suite.repo.GetAll(context.Background(), ProductQuery{Limit: 2, SellerID: "seller1"})
+	suite.NoError(err)
+	suite.Len(page.Items, 2, "expected the extra row to be trimmed")
+	suite.NotEmpty(page.NextCursor, "expected a cursor for the next page")
+
+	suite.mock.ExpectQuery("SELECT .* FROM products WHERE deleted_at IS NULL AND seller_id = \\$1 AND created_at >= \\$2 AND \\(created_at, id\\) > \\(\\$2, \\$3\\) ORDER BY created_at ASC, id ASC LIMIT \\$4").
+		WithArgs("seller1", createdAt.Add(time.Second), "2", 3).
+		WillReturnRows(sqlmock.NewRows(productColumnNames).AddRow("3", "Product 3", 30.0, "seller1", 1, createdAt.Add(2*time.Second), createdAt, nil, 0, "USD", 1))
+
+	next, err := suite.repo.GetAll(context.Background(), ProductQuery{Limit: 2, SellerID: "seller1", Cursor: page.NextCursor})
+	suite.NoError(err)
+	suite.Len(next.Items, 1)
+	suite.Equal("3", next.Items[0].ID)
+	suite.Empty(next.NextCursor)
+}
+
+func (suite *ProductRepositoryTestSuite) TestGetAllProductsRejectsMismatchedCursor() {
+	cursor := ProductQuery{SortBy: SortByPrice}.nextCursor(MockProduct())
+
+	_, err := suite.repo.GetAll(context.Background(), ProductQuery{Cursor: cursor})
+	suite.ErrorIs(err, ErrInvalidCursor, "expected a price cursor to be rejected for a created_at sort")
+
+	_, err = suite.repo.GetAll(context.Background(), ProductQuery{Cursor: "not-a-cursor"})
+	suite.ErrorIs(err, ErrInvalidCursor)
+}
+
+func (suite *ProductRepositoryTestSuite) TestRestoreProduct() {
+	fixedTime := time.Now()
+	suite.mock.ExpectQuery("UPDATE products SET deleted_at = NULL.* WHERE id = .* AND deleted_at IS NOT NULL RETURNING .*").WithArgs("1").
+		WillReturnRows(sqlmock.NewRows(productColumnNames).AddRow("1", "Test Product", 9.99, "", 100, fixedTime, fixedTime, nil, 0, "USD", 1))
+
+	product, err := suite.repo.RestoreProduct(context.Background(), "1")
+	suite.NoError(err, "expected no error while restoring product")
+	suite.False(product.IsDeleted(), "expected restored product to have no deleted_at")
+}
+
+func (suite *ProductRepositoryTestSuite) TestPurgeDeleted() {
+	before := time.Now().Add(-24 * time.Hour)
+	suite.mock.ExpectExec("DELETE FROM products p WHERE p.deleted_at IS NOT NULL AND p.deleted_at < \\$1\\s+AND NOT EXISTS \\(SELECT 1 FROM orders").WithArgs(before).WillReturnResult(sqlmock.NewResult(0, 3))
+
+	purged, err := suite.repo.PurgeDeleted(context.Background(), before)
+	suite.NoError(err, "expected no error while purging products")
+	suite.Equal(int64(3), purged)
+}
+
 func TestProductRepositoryTestSuite(t *testing.T) {
 	suite.Run(t, new(ProductRepositoryTestSuite))
 }
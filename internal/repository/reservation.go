@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"products-api/internal/models"
+	"time"
+)
+
+const reservationColumns = "id, product_id, cart_id, quantity, status, expires_at, created_at, updated_at"
+
+type ReservationRepository struct {
+	db DBTX
+}
+
+func NewReservationRepository(db DBTX) *ReservationRepository {
+	return &ReservationRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx.
+func (r *ReservationRepository) WithTx(tx *sql.Tx) *ReservationRepository {
+	return &ReservationRepository{db: tx}
+}
+
+func scanReservation(row rowScanner, res *models.Reservation) error {
+	err := row.Scan(&res.ID, &res.ProductID, &res.CartID, &res.Quantity, &res.Status, &res.ExpiresAt, &res.CreatedAt, &res.UpdatedAt)
+	return notFound(err, ErrReservationNotFound)
+}
+
+// Create inserts a reservation and fills in the timestamps assigned by the database.
+func (r *ReservationRepository) Create(ctx context.Context, res *models.Reservation) error {
+	query := `INSERT INTO reservations (id, product_id, cart_id, quantity, status, expires_at, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW()) RETURNING ` + reservationColumns
+	row := r.db.QueryRowContext(ctx, query, res.ID, res.ProductID, res.CartID, res.Quantity, res.Status, res.ExpiresAt)
+	return scanReservation(row, res)
+}
+
+// GetByID returns a reservation or ErrReservationNotFound.
+func (r *ReservationRepository) GetByID(ctx context.Context, id string) (*models.Reservation, error) {
+	query := "SELECT " + reservationColumns + " FROM reservations WHERE id = $1"
+	var res models.Reservation
+	if err := scanReservation(r.db.QueryRowContext(ctx, query, id), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetByIDForUpdate returns a reservation and locks its row until the
+// surrounding transaction ends. It must be called on a repository bound to a transaction.
+func (r *ReservationRepository) GetByIDForUpdate(ctx context.Context, id string) (*models.Reservation, error) {
+	query := "SELECT " + reservationColumns + " FROM reservations WHERE id = $1 FOR UPDATE"
+	var res models.Reservation
+	if err := scanReservation(r.db.QueryRowContext(ctx, query, id), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// UpdateStatus moves a reservation to a new status.
+func (r *ReservationRepository) UpdateStatus(ctx context.Context, res *models.Reservation, status models.ReservationStatus) error {
+	query := "UPDATE reservations SET status = $1, updated_at = NOW() WHERE id = $2 RETURNING " + reservationColumns
+	return scanReservation(r.db.QueryRowContext(ctx, query, status, res.ID), res)
+}
+
+// ReleaseByProduct releases every pending reservation of a product and
+// returns how many were released.
+func (r *ReservationRepository) ReleaseByProduct(ctx context.Context, productID string) (int64, error) {
+	query := "UPDATE reservations SET status = $1, updated_at = NOW() WHERE product_id = $2 AND status = $3"
+	result, err := r.db.ExecContext(ctx, query, models.ReservationReleased, productID, models.ReservationPending)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ListExpired returns up to limit pending reservations that expired before
+// now, without locking them. Their products must be locked before the
+// reservations themselves, so a sweeper locks each one once it knows its product.
+func (r *ReservationRepository) ListExpired(ctx context.Context, now time.Time, limit int) ([]models.Reservation, error) {
+	query := "SELECT " + reservationColumns + ` FROM reservations
+	          WHERE status = $1 AND expires_at < $2 ORDER BY expires_at LIMIT $3`
+	rows, err := r.db.QueryContext(ctx, query, models.ReservationPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reservations []models.Reservation
+	for rows.Next() {
+		var res models.Reservation
+		if err := scanReservation(rows, &res); err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, res)
+	}
+	return reservations, rows.Err()
+}
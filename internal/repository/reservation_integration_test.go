@@ -0,0 +1,78 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"products-api/internal/models"
+	"products-api/internal/money"
+	"products-api/internal/repository"
+	"products-api/internal/services"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIntegrationConcurrentDeleteAndConfirm(t *testing.T) {
+	db := repository.StartPostgres(t)
+	products := repository.NewProductRepository(db)
+	reservations := repository.NewReservationRepository(db)
+	outbox := repository.NewOutboxRepository(db)
+	tx := repository.NewTransactor(db)
+	productService := services.NewProductService(products, reservations, repository.NewInboxRepository(db), outbox, tx)
+	reservationService := services.NewReservationService(tx, products, reservations, outbox, time.Minute, time.Hour)
+	ctx := context.Background()
+
+	const rounds, carts = 10, 20
+	for round := 0; round < rounds; round++ {
+		product := models.Product{Name: "Contested", Price: money.New(1000, "USD"), Quantity: carts}
+		if err := productService.Create(ctx, &product); err != nil {
+			t.Fatalf("could not create product: %v", err)
+		}
+		ids := make([]string, carts)
+		for i := range ids {
+			reservation, err := reservationService.Reserve(ctx, product.ID, "cart", 1, 0)
+			if err != nil {
+				t.Fatalf("could not reserve: %v", err)
+			}
+			ids[i] = reservation.ID
+		}
+
+		var (
+			wg        sync.WaitGroup
+			mu        sync.Mutex
+			confirmed int
+		)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := productService.DeleteProduct(ctx, product.ID, services.Precondition{Any: true}); err != nil {
+				t.Errorf("unexpected delete error: %v", err)
+			}
+		}()
+		for _, id := range ids {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := reservationService.Confirm(ctx, id)
+				switch {
+				case err == nil:
+					mu.Lock()
+					confirmed++
+					mu.Unlock()
+				case errors.Is(err, services.ErrReservationNotPending):
+				default:
+					t.Errorf("unexpected confirm error: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		stored, err := products.FindProductByID(ctx, product.ID, true)
+		if err != nil {
+			t.Fatalf("could not reload product: %v", err)
+		}
+		if stored.Quantity != carts-confirmed || stored.ReservedQuantity != 0 {
+			t.Errorf("expected %d units left and none reserved; got %d and %d", carts-confirmed, stored.Quantity, stored.ReservedQuantity)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"products-api/internal/handlers"
+	"products-api/internal/server"
+)
+
+type OrderRoutes struct {
+	handler handlers.OrderHandler
+}
+
+func NewOrderRoutes(handler handlers.OrderHandler) *OrderRoutes {
+	return &OrderRoutes{handler: handler}
+}
+
+func (r *OrderRoutes) RegisterRoutes(server *server.FiberServer) {
+
+	server.App.Post("/orders", r.handler.CreateOrder)
+	server.App.Get("/orders", r.handler.GetOrders)
+	server.App.Get("/orders/:id", r.handler.GetOrder)
+}
@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"products-api/internal/handlers"
+	"products-api/internal/server"
+)
+
+type PriceRoutes struct {
+	handler handlers.PriceHandler
+}
+
+func NewPriceRoutes(handler handlers.PriceHandler) *PriceRoutes {
+	return &PriceRoutes{handler: handler}
+}
+
+func (r *PriceRoutes) RegisterRoutes(server *server.FiberServer) {
+
+	server.App.Get("/products/:id/prices", r.handler.GetPrices)
+	server.App.Put("/products/:id/prices/:currency", r.handler.SetPrice)
+	server.App.Delete("/products/:id/prices/:currency", r.handler.DeletePrice)
+	server.App.Get("/exchange-rates", r.handler.GetExchangeRates)
+	server.App.Post("/exchange-rates", r.handler.CreateExchangeRate)
+}
@@ -16,5 +16,12 @@ func NewProductRoutes(handler handlers.ProductHandler) *ProductRoutes {
 func (r *ProductRoutes) RegisterRoutes(server *server.FiberServer) {
 
 	server.App.Post("/products", r.hander.CreateProduct)
+	server.App.Post("/products/import", r.hander.ImportProducts)
 	server.App.Get("/products", r.hander.GetProducts)
+	server.App.Get("/products/:id", r.hander.GetProduct)
+	server.App.Put("/products/:id", r.hander.UpdateProduct)
+	server.App.Patch("/products/:id", r.hander.PatchProduct)
+	server.App.Delete("/products/:id", r.hander.DeleteProduct)
+	server.App.Post("/products/:id/restore", r.hander.RestoreProduct)
+	server.App.Post("/products/:id/stock/decrement", r.hander.DecrementStock)
 }
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"products-api/internal/handlers"
+	"products-api/internal/server"
+)
+
+type ReservationRoutes struct {
+	handler handlers.ReservationHandler
+}
+
+func NewReservationRoutes(handler handlers.ReservationHandler) *ReservationRoutes {
+	return &ReservationRoutes{handler: handler}
+}
+
+func (r *ReservationRoutes) RegisterRoutes(server *server.FiberServer) {
+
+	server.App.Post("/products/:id/reservations", r.handler.CreateReservation)
+	server.App.Get("/reservations/:id", r.handler.GetReservation)
+	server.App.Post("/reservations/:id/confirm", r.handler.ConfirmReservation)
+	server.App.Post("/reservations/:id/release", r.handler.ReleaseReservation)
+}
@@ -0,0 +1,309 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"products-api/internal/apperr"
+	"products-api/internal/broker"
+)
+
+const (
+	defaultMaxReceiveCount = 5
+	defaultBaseBackoff     = 5 * time.Second
+	defaultMaxBackoff      = 15 * time.Minute
+	// maxVisibilityTimeout is the longest visibility timeout SQS accepts
+	maxVisibilityTimeout = 12 * time.Hour
+	// maxMessageAttributes is the most message attributes SQS accepts per message
+	maxMessageAttributes = 10
+
+	// Message attributes added when a message is moved to a dead-letter queue
+	attrSourceQueue   = "dlq_source_queue"
+	attrFailureReason = "dlq_failure_reason"
+	attrReceiveCount  = "dlq_receive_count"
+)
+
+// queueName returns the queue name at the end of an SQS queue URL
+func queueName(queueURL string) string {
+	return queueURL[strings.LastIndex(queueURL, "/")+1:]
+}
+
+// backoff returns the visibility delay after the given delivery failed
+func (p *MessageProcessor) backoff(deliveries int) time.Duration {
+	delay := p.baseBackoff
+	for i := 1; i < deliveries && delay < p.maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, p.maxBackoff, maxVisibilityTimeout)
+}
+
+// handleFailure decides what happens to a message whose handler failed: it is
+// moved to the dead-letter queue once it has used up its deliveries or when
+// the handler returned ErrDeadLetter, and otherwise hidden for an
+// exponentially growing delay before being retried.
+func (s *FiberServer) handleFailure(ctx context.Context, processor *MessageProcessor, msg *broker.Message, cause error) {
+	deliveries := max(msg.ReceiveCount, 1)
+	if deliveries >= processor.maxReceiveCount || errors.Is(cause, ErrDeadLetter) {
+		if processor.dlqURL != "" {
+			if err := s.deadLetter(ctx, processor, msg, cause, deliveries); err != nil {
+				log.Printf("Failed to dead-letter message %s: %v", msg.ID, err)
+			}
+			return
+		}
+		log.Printf("Message %s failed %d times and %s has no dead-letter queue; retrying", msg.ID, deliveries, processor.name)
+	}
+
+	if err := s.consumer.ChangeVisibility(ctx, processor.queueURL, msg.Receipt, processor.backoff(deliveries)); err != nil {
+		log.Printf("Failed to delay retry of message %s: %v", msg.ID, err)
+	}
+}
+
+// deadLetter copies a message to the processor's dead-letter queue, recording
+// where it came from and why it failed, then removes it from the source queue.
+func (s *FiberServer) deadLetter(ctx context.Context, processor *MessageProcessor, msg *broker.Message, cause error, deliveries int) error {
+	attributes := map[string]string{
+		attrSourceQueue:   processor.queueURL,
+		attrFailureReason: cause.Error(),
+		attrReceiveCount:  strconv.Itoa(deliveries),
+	}
+	for name, value := range msg.Attributes {
+		if len(attributes) >= maxMessageAttributes {
+			break
+		}
+		if _, reserved := attributes[name]; !reserved {
+			attributes[name] = value
+		}
+	}
+
+	if _, err := s.consumer.Send(ctx, processor.dlqURL, broker.OutgoingMessage{Body: msg.Body, Attributes: attributes}); err != nil {
+		return err
+	}
+	log.Printf("Moved message %s to dead-letter queue after %d deliveries: %v", msg.ID, deliveries, cause)
+
+	failures, err := s.consumer.Delete(ctx, processor.queueURL, msg.Receipt)
+	if err == nil && len(failures) > 0 {
+		err = fmt.Errorf("delete from %s: %s", processor.name, failures[0].Code)
+	}
+	return err
+}
+
+// processorByName finds the message processor for a queue name
+func (s *FiberServer) processorByName(name string) *MessageProcessor {
+	for _, processor := range s.processors {
+		if processor.name == name {
+			return processor
+		}
+	}
+	return nil
+}
+
+// errDLQNotFound is returned for a queue that has no dead-letter queue
+var errDLQNotFound = apperr.New(apperr.NotFound, "dlq_not_found", "no dead-letter queue configured for this queue")
+
+// dlqUnreadable reports a failure to read the dead-letter queue at url
+func dlqUnreadable(url string, err error) error {
+	return apperr.Wrap(apperr.BadGateway, "dlq_unavailable", "failed to read dead-letter queue", fmt.Errorf("%s: %w", url, err))
+}
+
+// dlqProcessor resolves the :queue route parameter to a processor with a dead-letter queue
+func (s *FiberServer) dlqProcessor(c *fiber.Ctx) (*MessageProcessor, error) {
+	if s.consumer == nil {
+		return nil, errBrokerUnavailable
+	}
+	processor := s.processorByName(c.Params("queue"))
+	if processor == nil || processor.dlqURL == "" {
+		return nil, errDLQNotFound
+	}
+	return processor, nil
+}
+
+// dlqMessage is the admin view of a dead-lettered message
+func dlqMessage(msg broker.Message) fiber.Map {
+	return fiber.Map{
+		"messageId":    msg.ID,
+		"body":         msg.Body,
+		"attributes":   msg.Attributes,
+		"receiveCount": msg.ReceiveCount,
+		"sentAt":       msg.SentAt,
+	}
+}
+
+// peekDLQ receives up to max messages from a dead-letter queue without hiding them
+func (s *FiberServer) peekDLQ(ctx context.Context, processor *MessageProcessor, max int) ([]broker.Message, error) {
+	return s.consumer.Receive(ctx, processor.dlqURL, broker.ReceiveOptions{MaxMessages: max, Peek: true})
+}
+
+// listDLQsHandler lists the dead-letter queues of all processors with their approximate depth
+func (s *FiberServer) listDLQsHandler(c *fiber.Ctx) error {
+	if s.consumer == nil {
+		return errBrokerUnavailable
+	}
+	queues := []fiber.Map{}
+	for _, processor := range s.processors {
+		if processor.dlqURL == "" {
+			continue
+		}
+		entry := fiber.Map{
+			"queue":           processor.name,
+			"queueUrl":        processor.queueURL,
+			"dlqUrl":          processor.dlqURL,
+			"maxReceiveCount": processor.maxReceiveCount,
+		}
+		depth, err := s.consumer.Depth(c.Context(), processor.dlqURL)
+		if err != nil {
+			log.Printf("Failed to get depth of %s: %v", processor.dlqURL, err)
+		} else {
+			entry["approximateMessages"] = depth
+		}
+		queues = append(queues, entry)
+	}
+	return c.JSON(fiber.Map{"queues": queues})
+}
+
+// listDLQMessagesHandler peeks at up to ?max= (1-10) messages in a dead-letter queue
+func (s *FiberServer) listDLQMessagesHandler(c *fiber.Ctx) error {
+	processor, err := s.dlqProcessor(c)
+	if err != nil {
+		return err
+	}
+	max := c.QueryInt("max", 10)
+	if max < 1 || max > 10 {
+		return apperr.InvalidParameter("max must be between 1 and 10")
+	}
+	messages, err := s.peekDLQ(c.Context(), processor, max)
+	if err != nil {
+		return dlqUnreadable(processor.dlqURL, err)
+	}
+	views := []fiber.Map{}
+	for _, msg := range messages {
+		views = append(views, dlqMessage(msg))
+	}
+	return c.JSON(fiber.Map{"messages": views})
+}
+
+// getDLQMessageHandler looks for one message by ID. SQS cannot fetch a message
+// directly, so a few batches are sampled and a miss answers 404.
+func (s *FiberServer) getDLQMessageHandler(c *fiber.Ctx) error {
+	processor, err := s.dlqProcessor(c)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; attempt < 5; attempt++ {
+		messages, err := s.peekDLQ(c.Context(), processor, 10)
+		if err != nil {
+			return dlqUnreadable(processor.dlqURL, err)
+		}
+		for _, msg := range messages {
+			if msg.ID == c.Params("messageId") {
+				return c.JSON(dlqMessage(msg))
+			}
+		}
+	}
+	return apperr.New(apperr.NotFound, "message_not_found", "message not found in dead-letter queue")
+}
+
+// redriveDLQHandler moves messages from a dead-letter queue back to its source
+// queue. The body may list message_ids to move; otherwise up to max (default
+// 10, at most 100) messages are moved. Selected messages are looked for in one
+// sweep of the queue: messages that were not selected stay hidden until it
+// ends, and the IDs it did not come across are reported as not_found.
+func (s *FiberServer) redriveDLQHandler(c *fiber.Ctx) error {
+	processor, err := s.dlqProcessor(c)
+	if err != nil {
+		return err
+	}
+	var body struct {
+		MessageIDs []string `json:"message_ids"`
+		Max        int      `json:"max"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return apperr.ErrInvalidBody
+		}
+	}
+	if body.Max <= 0 {
+		body.Max = 10
+	}
+	body.Max = min(body.Max, 100)
+
+	wanted := make(map[string]bool, len(body.MessageIDs))
+	for _, id := range body.MessageIDs {
+		wanted[id] = true
+	}
+
+	var skipped []string
+	defer func() {
+		// Make the messages that were not selected visible again
+		for _, receipt := range skipped {
+			if err := s.consumer.ChangeVisibility(context.Background(), processor.dlqURL, receipt, 0); err != nil {
+				log.Printf("Failed to release skipped message in %s: %v", processor.dlqURL, err)
+			}
+		}
+	}()
+
+	redriven, failed := []string{}, 0
+	for len(redriven) < body.Max {
+		messages, err := s.consumer.Receive(c.Context(), processor.dlqURL, broker.ReceiveOptions{
+			MaxMessages:       min(10, body.Max-len(redriven)),
+			VisibilityTimeout: 30 * time.Second,
+		})
+		if err != nil {
+			return dlqUnreadable(processor.dlqURL, err)
+		}
+		if len(messages) == 0 {
+			break
+		}
+		for _, msg := range messages {
+			if len(body.MessageIDs) > 0 && !wanted[msg.ID] {
+				skipped = append(skipped, msg.Receipt)
+				continue
+			}
+			delete(wanted, msg.ID)
+			if err := s.redrive(c.Context(), processor, msg); err != nil {
+				log.Printf("Failed to redrive message %s: %v", msg.ID, err)
+				failed++
+				continue
+			}
+			redriven = append(redriven, msg.ID)
+		}
+		if len(body.MessageIDs) > 0 && len(wanted) == 0 {
+			break
+		}
+	}
+
+	notFound := []string{}
+	for _, id := range body.MessageIDs {
+		if wanted[id] {
+			notFound = append(notFound, id)
+		}
+	}
+	return c.JSON(fiber.Map{"redriven": redriven, "failed": failed, "not_found": notFound})
+}
+
+// redrive sends a dead-lettered message back to its source queue without the
+// dead-letter bookkeeping attributes, then deletes it from the dead-letter queue.
+func (s *FiberServer) redrive(ctx context.Context, processor *MessageProcessor, msg broker.Message) error {
+	attributes := make(map[string]string, len(msg.Attributes))
+	for name, value := range msg.Attributes {
+		switch name {
+		case attrSourceQueue, attrFailureReason, attrReceiveCount:
+		default:
+			attributes[name] = value
+		}
+	}
+	if _, err := s.consumer.Send(ctx, processor.queueURL, broker.OutgoingMessage{Body: msg.Body, Attributes: attributes}); err != nil {
+		return fmt.Errorf("send to %s: %w", processor.name, err)
+	}
+	failures, err := s.consumer.Delete(ctx, processor.dlqURL, msg.Receipt)
+	if err == nil && len(failures) > 0 {
+		err = fmt.Errorf("delete from dead-letter queue: %s", failures[0].Code)
+	}
+	return err
+}
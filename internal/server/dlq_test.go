@@ -0,0 +1,190 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"products-api/internal/broker"
+)
+
+func TestAddMessageProcessorOptions(t *testing.T) {
+	s := &FiberServer{}
+	noop := func(ctx context.Context, msg *broker.Message) error { return nil }
+
+	s.AddMessageProcessor("http://localhost:4566/000000000000/Orders", noop)
+	s.AddMessageProcessor("http://localhost:4566/000000000000/Payments", noop,
+		WithMaxReceiveCount(3),
+		WithBackoff(time.Second, time.Minute),
+		WithDeadLetterQueue("http://localhost:4566/000000000000/Payments-dlq"),
+	)
+
+	defaults := s.processors[0]
+	if defaults.name != "Orders" || defaults.maxReceiveCount != defaultMaxReceiveCount || defaults.dlqURL != "" {
+		t.Errorf("unexpected defaults: %+v", defaults)
+	}
+	configured := s.processorByName("Payments")
+	if configured == nil || configured.maxReceiveCount != 3 || configured.baseBackoff != time.Second ||
+		configured.maxBackoff != time.Minute || configured.dlqURL == "" {
+		t.Errorf("options not applied: %+v", configured)
+	}
+}
+
+func TestProcessorBackoff(t *testing.T) {
+	p := &MessageProcessor{baseBackoff: 5 * time.Second, maxBackoff: time.Minute}
+	tests := []struct {
+		deliveries int
+		want       time.Duration
+	}{
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{3, 20 * time.Second},
+		{4, 40 * time.Second},
+		{5, time.Minute},
+		{50, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := p.backoff(tt.deliveries); got != tt.want {
+			t.Errorf("backoff(%d) = %s; want %s", tt.deliveries, got, tt.want)
+		}
+	}
+}
+
+func TestDLQAdminRoutes(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	s := &FiberServer{App: app}
+	s.AddMessageProcessor("http://localhost:4566/000000000000/Orders", func(ctx context.Context, msg *broker.Message) error { return nil })
+	app.Get("/admin/dlq/:queue/messages", s.listDLQMessagesHandler)
+
+	// Without a broker the admin endpoints are unavailable
+	resp, err := app.Test(httptest.NewRequest("GET", "/admin/dlq/Orders/messages", nil))
+	if err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusServiceUnavailable {
+		t.Errorf("expected status 503; got %d", resp.StatusCode)
+	}
+
+	// A queue without a dead-letter queue, or an unknown queue, is not found
+	s.SetBroker(broker.NewMemory())
+	for _, queue := range []string{"Orders", "Unknown"} {
+		resp, err := app.Test(httptest.NewRequest("GET", "/admin/dlq/"+queue+"/messages", nil))
+		if err != nil {
+			t.Fatalf("error making request: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusNotFound {
+			t.Errorf("%s: expected status 404; got %d", queue, resp.StatusCode)
+		}
+	}
+}
+
+func TestConfigureMessaging(t *testing.T) {
+	s := &FiberServer{}
+	s.SetBroker(broker.NewMemory())
+	noop := func(ctx context.Context, msg *broker.Message) error { return nil }
+
+	cfg := &broker.Config{
+		Topics: map[string]broker.TopicSpec{"product-events": {Name: "ProductEvents"}},
+		Subscriptions: []broker.SubscriptionSpec{{
+			Queue:             "orders",
+			Handler:           "router",
+			DeadLetterQueue:   "orders-dlq",
+			Workers:           4,
+			VisibilityTimeout: broker.Duration(time.Minute),
+		}},
+	}
+	topics, err := s.ConfigureMessaging(context.Background(), cfg, map[string]MessageHandler{"router": noop})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if topics["product-events"] != "ProductEvents" {
+		t.Errorf("unexpected topics: %v", topics)
+	}
+	p := s.processorByName("orders")
+	if p == nil || p.dlqURL != "orders-dlq" || p.workers != 4 || p.visibilityTimeout != time.Minute || p.maxMessages != defaultMaxMessages {
+		t.Errorf("unexpected processor: %+v", p)
+	}
+
+	cfg.Subscriptions[0].Handler = "missing"
+	if _, err := s.ConfigureMessaging(context.Background(), cfg, map[string]MessageHandler{"router": noop}); err == nil {
+		t.Errorf("expected an error for an unknown handler")
+	}
+}
+
+func TestDLQPeekLeavesMessagesVisible(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	s := &FiberServer{App: app}
+	memory := broker.NewMemory()
+	s.SetBroker(memory)
+	s.AddMessageProcessor("orders", func(ctx context.Context, msg *broker.Message) error { return nil }, WithDeadLetterQueue("orders-dlq"))
+	app.Get("/admin/dlq/:queue/messages", s.listDLQMessagesHandler)
+
+	ctx := context.Background()
+	memory.Send(ctx, "orders-dlq", broker.OutgoingMessage{Body: "one"})
+	memory.Send(ctx, "orders-dlq", broker.OutgoingMessage{Body: "two"})
+
+	for range 2 {
+		resp, err := app.Test(httptest.NewRequest("GET", "/admin/dlq/orders/messages", nil))
+		if err != nil {
+			t.Fatalf("error making request: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("expected status 200; got %d", resp.StatusCode)
+		}
+	}
+
+	// A redrive right after the peeks still finds every message
+	received, _ := memory.Receive(ctx, "orders-dlq", broker.ReceiveOptions{MaxMessages: 10})
+	if len(received) != 2 {
+		t.Errorf("expected both messages to stay visible after peeking; got %d", len(received))
+	}
+}
+
+func TestDLQRedriveSelectedMessages(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	s := &FiberServer{App: app}
+	memory := broker.NewMemory()
+	s.SetBroker(memory)
+	s.AddMessageProcessor("orders", func(ctx context.Context, msg *broker.Message) error { return nil }, WithDeadLetterQueue("orders-dlq"))
+	app.Post("/admin/dlq/:queue/redrive", s.redriveDLQHandler)
+
+	ctx := context.Background()
+	selected, _ := memory.Send(ctx, "orders-dlq", broker.OutgoingMessage{Body: "one"})
+	memory.Send(ctx, "orders-dlq", broker.OutgoingMessage{Body: "two"})
+
+	body := `{"message_ids": ["` + selected + `", "missing"]}`
+	req := httptest.NewRequest("POST", "/admin/dlq/orders/redrive", strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	var result struct {
+		Redriven []string `json:"redriven"`
+		NotFound []string `json:"not_found"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if len(result.Redriven) != 1 || result.Redriven[0] != selected {
+		t.Errorf("expected %s to be redriven; got %v", selected, result.Redriven)
+	}
+	if len(result.NotFound) != 1 || result.NotFound[0] != "missing" {
+		t.Errorf("expected missing to be reported as not found; got %v", result.NotFound)
+	}
+
+	// The message that was not selected is visible again and was received only once
+	received, _ := memory.Receive(ctx, "orders-dlq", broker.ReceiveOptions{MaxMessages: 10})
+	if len(received) != 1 || received[0].Body != "two" || received[0].ReceiveCount != 2 {
+		t.Errorf("expected the unselected message back after one receive; got %+v", received)
+	}
+	received, _ = memory.Receive(ctx, "orders", broker.ReceiveOptions{MaxMessages: 10})
+	if len(received) != 1 || received[0].Body != "one" {
+		t.Errorf("expected the selected message on the source queue; got %+v", received)
+	}
+}
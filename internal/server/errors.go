@@ -0,0 +1,99 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"products-api/internal/apperr"
+	"products-api/internal/validation"
+)
+
+// MIMEProblemJSON is the media type of RFC 7807 problem details
+const MIMEProblemJSON = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object. Code is a stable identifier
+// clients can branch on; TraceID matches the X-Request-ID response header and
+// the server log line of a failed request.
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Code     string            `json:"code"`
+	TraceID  string            `json:"trace_id,omitempty"`
+	Errors   validation.Errors `json:"errors,omitempty"`
+}
+
+var errBrokerUnavailable = apperr.New(apperr.Unavailable, "broker_unavailable", "message broker not initialized")
+
+// ErrorHandler answers every error returned by a handler with a problem
+// document. Application errors and validation errors keep their message;
+// anything else is reported as an internal error without detail. Server
+// errors are logged with their cause and trace ID.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	problem := problemFor(err)
+	problem.Instance = c.Path()
+	problem.TraceID = c.GetRespHeader(fiber.HeaderXRequestID)
+	if problem.Status >= fiber.StatusInternalServerError {
+		log.Printf("%s %s failed [trace_id=%s]: %v", c.Method(), c.Path(), problem.TraceID, err)
+	}
+	return c.Status(problem.Status).JSON(problem, MIMEProblemJSON)
+}
+
+func problemFor(err error) Problem {
+	var fields validation.Errors
+	if errors.As(err, &fields) {
+		problem := newProblem(fiber.StatusUnprocessableEntity, "validation_failed", "the request has invalid fields")
+		problem.Errors = fields
+		return problem
+	}
+	if appErr, ok := apperr.As(err); ok {
+		return newProblem(statusFor(appErr.Kind), appErr.Code, appErr.Message)
+	}
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) && fiberErr.Code < fiber.StatusInternalServerError {
+		// Fiber's own errors, such as unmatched routes, carry no internal detail
+		code := strings.ToLower(strings.ReplaceAll(http.StatusText(fiberErr.Code), " ", "_"))
+		return newProblem(fiberErr.Code, code, fiberErr.Message)
+	}
+	return newProblem(fiber.StatusInternalServerError, "internal_error", "an unexpected error occurred")
+}
+
+func newProblem(status int, code, detail string) Problem {
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// statusFor maps an application error kind to its HTTP status
+func statusFor(kind apperr.Kind) int {
+	switch kind {
+	case apperr.BadRequest:
+		return fiber.StatusBadRequest
+	case apperr.NotFound:
+		return fiber.StatusNotFound
+	case apperr.Conflict, apperr.InsufficientStock:
+		return fiber.StatusConflict
+	case apperr.UnsupportedMediaType:
+		return fiber.StatusUnsupportedMediaType
+	case apperr.Unavailable:
+		return fiber.StatusServiceUnavailable
+	case apperr.BadGateway:
+		return fiber.StatusBadGateway
+	case apperr.PreconditionRequired:
+		return fiber.StatusPreconditionRequired
+	case apperr.PreconditionFailed:
+		return fiber.StatusPreconditionFailed
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
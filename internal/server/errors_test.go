@@ -0,0 +1,76 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+
+	"products-api/internal/repository"
+	"products-api/internal/validation"
+)
+
+func TestErrorHandler(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(requestid.New())
+	app.Get("/not-found", func(c *fiber.Ctx) error {
+		return fmt.Errorf("loading product: %w", repository.ErrProductNotFound)
+	})
+	app.Get("/invalid", func(c *fiber.Ctx) error {
+		return validation.Errors{{Field: "name", Message: "is required"}}
+	})
+	app.Get("/stock", func(c *fiber.Ctx) error {
+		return repository.ErrInsufficientStock
+	})
+	app.Get("/internal", func(c *fiber.Ctx) error {
+		return errors.New("pq: password authentication failed for user postgres")
+	})
+
+	tests := []struct {
+		path   string
+		status int
+		code   string
+	}{
+		{"/not-found", fiber.StatusNotFound, "product_not_found"},
+		{"/invalid", fiber.StatusUnprocessableEntity, "validation_failed"},
+		{"/stock", fiber.StatusConflict, "insufficient_stock"},
+		{"/internal", fiber.StatusInternalServerError, "internal_error"},
+		{"/missing", fiber.StatusNotFound, "not_found"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		req.Header.Set(fiber.HeaderXRequestID, "trace-"+tt.code)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s: error making request: %v", tt.path, err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: expected status %d; got %d", tt.path, tt.status, resp.StatusCode)
+		}
+		if ct := resp.Header.Get(fiber.HeaderContentType); !strings.HasPrefix(ct, MIMEProblemJSON) {
+			t.Errorf("%s: expected a problem document; got %q", tt.path, ct)
+		}
+
+		var problem Problem
+		if err := json.NewDecoder(resp.Body).Decode(&problem); err != nil {
+			t.Fatalf("%s: error decoding response: %v", tt.path, err)
+		}
+		if problem.Code != tt.code || problem.Status != tt.status || problem.Instance != tt.path {
+			t.Errorf("%s: unexpected problem %+v", tt.path, problem)
+		}
+		if problem.TraceID != "trace-"+tt.code {
+			t.Errorf("%s: expected the request ID as trace ID; got %q", tt.path, problem.TraceID)
+		}
+		if strings.Contains(problem.Detail, "password") || strings.Contains(problem.Detail, "no rows") {
+			t.Errorf("%s: detail leaks internal errors: %q", tt.path, problem.Detail)
+		}
+		if tt.code == "validation_failed" && (len(problem.Errors) != 1 || problem.Errors[0].Field != "name") {
+			t.Errorf("expected the invalid fields; got %+v", problem.Errors)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	statusUp   = "up"
+	statusDown = "down"
+
+	// readinessTimeout bounds every readiness check
+	readinessTimeout = 2 * time.Second
+	// processorStallTimeout is how long a running processor may go without
+	// receiving or finishing a message before it counts as stalled. It is well
+	// above the longest long-polling wait plus the back-off after a receive error.
+	processorStallTimeout = 2 * time.Minute
+)
+
+// ComponentHealth is the outcome of checking one dependency. Why a check
+// failed is logged rather than reported, as it can name queues and hosts.
+type ComponentHealth struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+}
+
+// HealthReport aggregates component checks; Status is down if any component is
+type HealthReport struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentHealth `json:"components,omitempty"`
+}
+
+// healthCheck checks one component, returning an error if it is unhealthy
+type healthCheck func(ctx context.Context) error
+
+// livenessHandler reports that the process is running and serving requests.
+// It checks no dependencies, so a database outage does not get it restarted.
+func (s *FiberServer) livenessHandler(c *fiber.Ctx) error {
+	return c.JSON(HealthReport{Status: statusUp})
+}
+
+// readinessHandler reports whether the server can do its work: Postgres and
+// SQS are reachable and every message processor is running. It answers 503
+// when any of them is not.
+func (s *FiberServer) readinessHandler(c *fiber.Ctx) error {
+	report := s.checkReadiness(c.UserContext())
+	if report.Status != statusUp {
+		c.Status(fiber.StatusServiceUnavailable)
+	}
+	return c.JSON(report)
+}
+
+// checkReadiness runs the readiness checks concurrently
+func (s *FiberServer) checkReadiness(ctx context.Context) HealthReport {
+	checks := map[string]healthCheck{
+		"postgres": s.checkDatabase,
+	}
+	if len(s.processors) > 0 {
+		checks["sqs"] = s.checkQueues
+		checks["message_processors"] = s.checkProcessors
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	report := HealthReport{Status: statusUp, Components: make(map[string]ComponentHealth, len(checks))}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check healthCheck) {
+			defer wg.Done()
+			start := time.Now()
+			err := check(ctx)
+			result := ComponentHealth{
+				Status:    statusUp,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				result.Status = statusDown
+				log.Printf("Readiness check %s failed: %v", name, err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[name] = result
+			if err != nil {
+				report.Status = statusDown
+			}
+		}(name, check)
+	}
+	wg.Wait()
+	return report
+}
+
+func (s *FiberServer) checkDatabase(ctx context.Context) error {
+	if s.db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	return s.db.Ping(ctx)
+}
+
+// checkQueues reads the depth of every consumed queue, which fails when SQS
+// cannot be reached or a queue no longer exists
+func (s *FiberServer) checkQueues(ctx context.Context) error {
+	if s.consumer == nil {
+		return fmt.Errorf("message broker not initialized")
+	}
+	for _, processor := range s.processors {
+		if _, err := s.consumer.Depth(ctx, processor.queueURL); err != nil {
+			return fmt.Errorf("queue %s: %w", processor.name, err)
+		}
+	}
+	return nil
+}
+
+// checkProcessors fails if a processor is not running or has stalled
+func (s *FiberServer) checkProcessors(ctx context.Context) error {
+	for _, processor := range s.processors {
+		if !processor.stats.running.Load() {
+			return fmt.Errorf("processor %s is not running", processor.name)
+		}
+		idle := time.Since(time.Unix(0, processor.stats.lastActive.Load()))
+		if idle > processorStallTimeout {
+			return fmt.Errorf("processor %s has made no progress for %s", processor.name, idle.Round(time.Second))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"products-api/internal/broker"
+)
+
+// stubDB is a database.Service whose ping result the test controls
+type stubDB struct {
+	err error
+}
+
+func (d *stubDB) Health() map[string]string {
+	if d.err != nil {
+		return map[string]string{"status": "down", "error": d.err.Error()}
+	}
+	return map[string]string{"status": "up"}
+}
+
+func (d *stubDB) Ping(ctx context.Context) error { return d.err }
+func (d *stubDB) Close() error                   { return nil }
+func (d *stubDB) GetDB() *sql.DB                 { return nil }
+
+func newHealthTestServer(db *stubDB) (*FiberServer, *fiber.App) {
+	app := fiber.New()
+	s := &FiberServer{App: app, db: db}
+	app.Get("/health", s.healthHandler)
+	app.Get("/livez", s.livenessHandler)
+	app.Get("/readyz", s.readinessHandler)
+	return s, app
+}
+
+func getHealth(t *testing.T, app *fiber.App, path string) (int, HealthReport) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+	if err != nil {
+		t.Fatalf("error making request to server. Err: %v", err)
+	}
+	var report HealthReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatalf("error decoding response. Err: %v", err)
+	}
+	return resp.StatusCode, report
+}
+
+func TestReadinessAggregatesComponents(t *testing.T) {
+	db := &stubDB{}
+	s, app := newHealthTestServer(db)
+	s.SetBroker(broker.NewMemory())
+	s.AddMessageProcessor("orders", nil)
+	s.processors[0].stats.running.Store(true)
+	s.processors[0].stats.touch()
+
+	status, report := getHealth(t, app, "/readyz")
+	if status != http.StatusOK || report.Status != statusUp {
+		t.Fatalf("expected ready; got %d %+v", status, report)
+	}
+	for _, name := range []string{"postgres", "sqs", "message_processors"} {
+		if report.Components[name].Status != statusUp {
+			t.Errorf("expected %s up; got %+v", name, report.Components[name])
+		}
+	}
+
+	db.err = errors.New("dial tcp db.internal:5432: connection refused")
+	resp, err := app.Test(httptest.NewRequest("GET", "/readyz", nil))
+	if err != nil {
+		t.Fatalf("error making request to server. Err: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if strings.Contains(string(body), "db.internal") {
+		t.Errorf("expected the readiness body to leave out the error; got %s", body)
+	}
+	if err := json.Unmarshal(body, &report); err != nil {
+		t.Fatalf("error decoding response. Err: %v", err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable || report.Status != statusDown {
+		t.Fatalf("expected 503 while the database is down; got %d %+v", resp.StatusCode, report)
+	}
+	if got := report.Components["postgres"]; got.Status != statusDown {
+		t.Errorf("unexpected postgres result: %+v", got)
+	}
+	if report.Components["sqs"].Status != statusUp {
+		t.Errorf("expected sqs to stay up; got %+v", report.Components["sqs"])
+	}
+}
+
+func TestReadinessDetectsStoppedAndStalledProcessors(t *testing.T) {
+	s, app := newHealthTestServer(&stubDB{})
+	s.SetBroker(broker.NewMemory())
+	s.AddMessageProcessor("orders", nil)
+
+	status, report := getHealth(t, app, "/readyz")
+	if status != http.StatusServiceUnavailable || report.Components["message_processors"].Status != statusDown {
+		t.Fatalf("expected a processor that never started to fail readiness; got %d %+v", status, report)
+	}
+
+	s.processors[0].stats.running.Store(true)
+	s.processors[0].stats.lastActive.Store(time.Now().Add(-processorStallTimeout - time.Minute).UnixNano())
+	status, _ = getHealth(t, app, "/readyz")
+	if status != http.StatusServiceUnavailable {
+		t.Fatalf("expected a stalled processor to fail readiness; got %d", status)
+	}
+}
+
+func TestVisibilityHeartbeatKeepsLongHandlersReady(t *testing.T) {
+	s, app := newHealthTestServer(&stubDB{})
+	memory := broker.NewMemory()
+	s.SetBroker(memory)
+	s.AddMessageProcessor("orders", nil)
+	processor := s.processors[0]
+	processor.visibilityTimeout = 20 * time.Millisecond
+
+	ctx := context.Background()
+	memory.Send(ctx, processor.queueURL, broker.OutgoingMessage{Body: "slow"})
+	messages, _ := memory.Receive(ctx, processor.queueURL, broker.ReceiveOptions{MaxMessages: 1})
+	if len(messages) != 1 {
+		t.Fatalf("expected a message to handle; got %d", len(messages))
+	}
+	processor.stats.running.Store(true)
+	processor.stats.lastActive.Store(time.Now().Add(-processorStallTimeout - time.Minute).UnixNano())
+
+	// A handler still running long after its message was received
+	stop := s.extendVisibility(ctx, processor, &messages[0])
+	time.Sleep(50 * time.Millisecond)
+	stop()
+
+	status, report := getHealth(t, app, "/readyz")
+	if status != http.StatusOK {
+		t.Fatalf("expected the heartbeat to keep the processor ready; got %d %+v", status, report)
+	}
+}
+
+func TestLivenessIgnoresDependencies(t *testing.T) {
+	_, app := newHealthTestServer(&stubDB{err: errors.New("connection refused")})
+
+	status, report := getHealth(t, app, "/livez")
+	if status != http.StatusOK || report.Status != statusUp {
+		t.Fatalf("expected live; got %d %+v", status, report)
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/health", nil))
+	if err != nil {
+		t.Fatalf("error making request to server. Err: %v", err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected /health to answer 503; got %v", resp.Status)
+	}
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Job is a background task that runs on a fixed interval until the server stops.
+type Job struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) error
+}
+
+// AddJob registers a periodic background job
+func (s *FiberServer) AddJob(name string, interval time.Duration, run func(ctx context.Context) error) {
+	s.jobs = append(s.jobs, &Job{
+		name:     name,
+		interval: interval,
+		run:      run,
+	})
+}
+
+// StartJobs starts all registered background jobs
+func (s *FiberServer) StartJobs() {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go s.runJob(job)
+	}
+}
+
+// runJob runs a job once per interval until the server context is cancelled
+func (s *FiberServer) runJob(job *Job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(job.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			log.Printf("Stopping background job: %s", job.name)
+			return
+		case <-ticker.C:
+			if err := job.run(s.ctx); err != nil {
+				log.Printf("Background job %s failed: %v", job.name, err)
+			}
+		}
+	}
+}
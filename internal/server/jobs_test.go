@@ -0,0 +1,37 @@
+package server
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestJobsRunUntilStopped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &FiberServer{ctx: ctx, cancel: cancel}
+
+	var runs atomic.Int32
+	s.AddJob("counter", 5*time.Millisecond, func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	})
+	s.StartJobs()
+
+	deadline := time.Now().Add(time.Second)
+	for runs.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := s.StopMessageProcessors(context.Background()); err != nil {
+		t.Fatalf("unexpected error stopping: %v", err)
+	}
+
+	if runs.Load() < 2 {
+		t.Fatalf("expected job to run at least twice; ran %d times", runs.Load())
+	}
+	stopped := runs.Load()
+	time.Sleep(20 * time.Millisecond)
+	if runs.Load() != stopped {
+		t.Errorf("expected job to stop running after shutdown")
+	}
+}
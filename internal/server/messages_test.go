@@ -0,0 +1,34 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"products-api/internal/broker"
+	"products-api/internal/events"
+)
+
+func TestHandleOrderCreatedRejectsInvalidPayloads(t *testing.T) {
+	s := &FiberServer{}
+	s.RegisterMessageHandlers(FallbackError)
+
+	bodies := map[string]string{
+		"not json":            `not json`,
+		"malformed order":     `{"Type":"Notification","MessageId":"m1","TopicArn":"arn:orders","Message":"not json"}`,
+		"order without items": `{"Type":"Notification","MessageId":"m1","TopicArn":"arn:orders","Message":"{\"order_id\":\"o1\",\"items\":[]}"}`,
+	}
+	for name, body := range bodies {
+		t.Run(name, func(t *testing.T) {
+			err := s.RouteMessage(context.Background(), &broker.Message{Body: body})
+			if !errors.Is(err, ErrDeadLetter) {
+				t.Fatalf("expected ErrDeadLetter so the message is dead-lettered; got %v", err)
+			}
+		})
+	}
+
+	body := `{"Type":"Notification","MessageId":"m1","TopicArn":"arn:orders","Message":"{\"order_id\":\"o1\",\"items\":[]}"}`
+	if err := s.RouteMessage(context.Background(), &broker.Message{Body: body}); !errors.Is(err, events.ErrInvalidEvent) {
+		t.Errorf("expected ErrInvalidEvent; got %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"products-api/internal/broker"
+)
+
+// ConfigureMessaging resolves the queues and topics declared in cfg, creating
+// them first when cfg.Provision is set, and adds a message processor for every
+// subscription. handlers maps the handler names used in cfg to handlers. It
+// returns the resolved topics, keyed like cfg.Topics.
+func (s *FiberServer) ConfigureMessaging(ctx context.Context, cfg *broker.Config, handlers map[string]MessageHandler) (map[string]string, error) {
+	if s.broker == nil {
+		return nil, errors.New("message broker not initialized")
+	}
+	for _, sub := range cfg.Subscriptions {
+		if _, ok := handlers[sub.Handler]; !ok {
+			return nil, fmt.Errorf("subscription %s: unknown handler %q", sub.Queue, sub.Handler)
+		}
+	}
+
+	resolved, err := broker.Resolve(ctx, s.broker, cfg)
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range resolved.Subscriptions {
+		s.AddMessageProcessor(sub.QueueURL, handlers[sub.Handler], subscriptionOptions(sub)...)
+	}
+	return resolved.Topics, nil
+}
+
+// subscriptionOptions turns the settings of a subscription into processor
+// options; settings left out keep the processor defaults
+func subscriptionOptions(sub broker.Subscription) []ProcessorOption {
+	opts := []ProcessorOption{
+		WithWorkers(sub.Workers),
+		WithMaxMessages(sub.MaxMessages),
+		WithMaxReceiveCount(sub.MaxReceiveCount),
+	}
+	if sub.WaitTime > 0 {
+		opts = append(opts, WithWaitTime(time.Duration(sub.WaitTime)))
+	}
+	if sub.VisibilityTimeout > 0 {
+		opts = append(opts, WithVisibilityTimeout(time.Duration(sub.VisibilityTimeout)))
+	}
+	if sub.BackoffBase > 0 || sub.BackoffMax > 0 {
+		base, max := time.Duration(sub.BackoffBase), time.Duration(sub.BackoffMax)
+		if base == 0 {
+			base = defaultBaseBackoff
+		}
+		if max == 0 {
+			max = defaultMaxBackoff
+		}
+		opts = append(opts, WithBackoff(base, max))
+	}
+	if sub.DLQURL != "" {
+		opts = append(opts, WithDeadLetterQueue(sub.DLQURL))
+	}
+	return opts
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// processorStats counts a processor's SQS calls and message outcomes since startup
+type processorStats struct {
+	receiveCalls   atomic.Int64
+	emptyReceives  atomic.Int64
+	receiveErrors  atomic.Int64
+	received       atomic.Int64
+	handled        atomic.Int64
+	failed         atomic.Int64
+	deleteCalls    atomic.Int64
+	deleted        atomic.Int64
+	deleteFailures atomic.Int64
+	// running is set while the receive loop runs; lastActive is when, in unix
+	// nanoseconds, a receive call last returned, empty or not, a handler last
+	// finished or the visibility of a message being handled was last extended
+	running    atomic.Bool
+	lastActive atomic.Int64
+}
+
+// touch records that the processor made progress
+func (s *processorStats) touch() {
+	s.lastActive.Store(time.Now().UnixNano())
+}
+
+// ProcessorMetrics is a snapshot of a processor's counters. The per-call
+// averages show how full receive and delete batches are; 10 is the best SQS allows.
+type ProcessorMetrics struct {
+	Queue              string  `json:"queue"`
+	ReceiveCalls       int64   `json:"receive_calls"`
+	EmptyReceives      int64   `json:"empty_receives"`
+	ReceiveErrors      int64   `json:"receive_errors"`
+	Received           int64   `json:"received"`
+	Handled            int64   `json:"handled"`
+	Failed             int64   `json:"failed"`
+	DeleteCalls        int64   `json:"delete_calls"`
+	Deleted            int64   `json:"deleted"`
+	DeleteFailures     int64   `json:"delete_failures"`
+	MessagesPerReceive float64 `json:"messages_per_receive"`
+	MessagesPerDelete  float64 `json:"messages_per_delete"`
+	DeleteCallsAvoided int64   `json:"delete_calls_avoided"`
+}
+
+// Metrics returns a snapshot of the processor's counters
+func (p *MessageProcessor) Metrics() ProcessorMetrics {
+	m := ProcessorMetrics{
+		Queue:          p.name,
+		ReceiveCalls:   p.stats.receiveCalls.Load(),
+		EmptyReceives:  p.stats.emptyReceives.Load(),
+		ReceiveErrors:  p.stats.receiveErrors.Load(),
+		Received:       p.stats.received.Load(),
+		Handled:        p.stats.handled.Load(),
+		Failed:         p.stats.failed.Load(),
+		DeleteCalls:    p.stats.deleteCalls.Load(),
+		Deleted:        p.stats.deleted.Load(),
+		DeleteFailures: p.stats.deleteFailures.Load(),
+	}
+	if m.ReceiveCalls > 0 {
+		m.MessagesPerReceive = float64(m.Received) / float64(m.ReceiveCalls)
+	}
+	if m.DeleteCalls > 0 {
+		m.MessagesPerDelete = float64(m.Deleted) / float64(m.DeleteCalls)
+	}
+	// One DeleteMessage call per message is what batching replaces
+	m.DeleteCallsAvoided = max(m.Deleted-m.DeleteCalls, 0)
+	return m
+}
+
+// processorMetricsHandler reports the counters of every message processor
+func (s *FiberServer) processorMetricsHandler(c *fiber.Ctx) error {
+	metrics := make([]ProcessorMetrics, 0, len(s.processors))
+	for _, processor := range s.processors {
+		metrics = append(metrics, processor.Metrics())
+	}
+	return c.JSON(fiber.Map{"processors": metrics})
+}
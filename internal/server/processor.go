@@ -0,0 +1,352 @@
+package server
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"products-api/internal/broker"
+)
+
+const (
+	defaultWorkers           = 1
+	defaultMaxMessages       = 10
+	defaultWaitTime          = 20 * time.Second
+	defaultVisibilityTimeout = 30 * time.Second
+
+	// maxBatchSize is the most messages SQS returns or deletes per call
+	maxBatchSize = 10
+	// maxWaitTime is the longest long-polling wait SQS accepts
+	maxWaitTime = 20 * time.Second
+	// deleteFlushInterval is how long a partial batch of handled messages
+	// waits for more before it is deleted
+	deleteFlushInterval = time.Second
+	// maxDeleteAttempts bounds retries of entries SQS failed to delete
+	maxDeleteAttempts = 3
+)
+
+// MessageHandler processes one message. ctx is cancelled when the server gives
+// up waiting for in-flight messages during shutdown.
+type MessageHandler func(ctx context.Context, msg *broker.Message) error
+
+type MessageProcessor struct {
+	name     string
+	queueURL string
+	handler  MessageHandler
+	// workers is how many messages of this queue are handled at the same time
+	workers int
+	// maxMessages and waitTime are passed to every ReceiveMessage call
+	maxMessages int
+	waitTime    time.Duration
+	// visibilityTimeout hides a received message from other consumers; it is
+	// extended for as long as the handler is still running
+	visibilityTimeout time.Duration
+	// maxReceiveCount is how many times a message is attempted before it is
+	// moved to the dead-letter queue
+	maxReceiveCount int
+	// baseBackoff and maxBackoff bound the exponential delay before a failed
+	// message becomes visible again
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+	dlqURL      string
+	// acks carries handled messages to the batch deleter
+	acks  chan broker.Message
+	stats processorStats
+}
+
+// ProcessorOption configures a MessageProcessor
+type ProcessorOption func(*MessageProcessor)
+
+// WithWorkers sets how many messages are handled concurrently. Messages of the
+// same queue may then complete out of order.
+func WithWorkers(n int) ProcessorOption {
+	return func(p *MessageProcessor) {
+		if n > 0 {
+			p.workers = n
+		}
+	}
+}
+
+// WithMaxMessages sets how many messages one ReceiveMessage call asks for (1-10)
+func WithMaxMessages(n int) ProcessorOption {
+	return func(p *MessageProcessor) {
+		if n > 0 && n <= maxBatchSize {
+			p.maxMessages = n
+		}
+	}
+}
+
+// WithWaitTime sets the long-polling wait of ReceiveMessage (0-20s). Zero
+// switches to short polling.
+func WithWaitTime(d time.Duration) ProcessorOption {
+	return func(p *MessageProcessor) {
+		if d >= 0 && d <= maxWaitTime {
+			p.waitTime = d
+		}
+	}
+}
+
+// WithVisibilityTimeout sets how long a received message stays hidden from
+// other consumers. It is renewed every half period while the handler runs, so
+// it only bounds how soon a message reappears after this process dies.
+func WithVisibilityTimeout(d time.Duration) ProcessorOption {
+	return func(p *MessageProcessor) {
+		if d >= time.Second && d <= maxVisibilityTimeout {
+			p.visibilityTimeout = d
+		}
+	}
+}
+
+// WithMaxReceiveCount sets how many deliveries a message gets before it is dead-lettered
+func WithMaxReceiveCount(n int) ProcessorOption {
+	return func(p *MessageProcessor) {
+		if n > 0 {
+			p.maxReceiveCount = n
+		}
+	}
+}
+
+// WithBackoff sets the retry delay for failed messages, which doubles on every
+// delivery from base up to max
+func WithBackoff(base, max time.Duration) ProcessorOption {
+	return func(p *MessageProcessor) {
+		if base > 0 && max >= base {
+			p.baseBackoff, p.maxBackoff = base, max
+		}
+	}
+}
+
+// WithDeadLetterQueue routes messages that exhaust their receive count to dlqURL
+func WithDeadLetterQueue(dlqURL string) ProcessorOption {
+	return func(p *MessageProcessor) {
+		p.dlqURL = dlqURL
+	}
+}
+
+// AddMessageProcessor adds a new message processor for a queue
+func (s *FiberServer) AddMessageProcessor(queueURL string, handler MessageHandler, opts ...ProcessorOption) {
+	processor := &MessageProcessor{
+		name:              queueName(queueURL),
+		queueURL:          queueURL,
+		handler:           handler,
+		workers:           defaultWorkers,
+		maxMessages:       defaultMaxMessages,
+		waitTime:          defaultWaitTime,
+		visibilityTimeout: defaultVisibilityTimeout,
+		maxReceiveCount:   defaultMaxReceiveCount,
+		baseBackoff:       defaultBaseBackoff,
+		maxBackoff:        defaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(processor)
+	}
+	s.processors = append(s.processors, processor)
+}
+
+// StartMessageProcessors starts all registered message processors
+func (s *FiberServer) StartMessageProcessors() {
+	for _, processor := range s.processors {
+		processor.acks = make(chan broker.Message, maxBatchSize)
+		s.wg.Add(2)
+		go s.processQueue(processor)
+		go s.deleteHandled(processor)
+	}
+}
+
+// processQueue continuously receives messages from a queue and hands them to a
+// pool of workers. It only asks SQS for as many messages as there are idle
+// workers, so no received message waits for a worker while its visibility
+// timeout runs out. Once stopped it waits for its workers and closes the
+// processor's acks so the deleter can flush.
+func (s *FiberServer) processQueue(processor *MessageProcessor) {
+	defer s.wg.Done()
+
+	processor.stats.running.Store(true)
+	processor.stats.touch()
+	defer processor.stats.running.Store(false)
+
+	var inFlight sync.WaitGroup
+	defer func() {
+		inFlight.Wait()
+		close(processor.acks)
+	}()
+
+	slots := make(chan struct{}, processor.workers)
+	for {
+		// Wait for at least one idle worker
+		select {
+		case <-s.ctx.Done():
+			log.Printf("Stopping message processor for queue: %s", processor.queueURL)
+			return
+		case slots <- struct{}{}:
+		}
+
+		// Only this goroutine takes slots, so the number of idle workers can
+		// grow but not shrink until the messages are dispatched below
+		idle := processor.workers - len(slots) + 1
+		processor.stats.receiveCalls.Add(1)
+		messages, err := s.consumer.Receive(s.ctx, processor.queueURL, broker.ReceiveOptions{
+			MaxMessages:       min(idle, processor.maxMessages),
+			WaitTime:          processor.waitTime,
+			VisibilityTimeout: processor.visibilityTimeout,
+		})
+		processor.stats.touch()
+		if err != nil || len(messages) == 0 {
+			<-slots
+			if err != nil && s.ctx.Err() == nil {
+				processor.stats.receiveErrors.Add(1)
+				log.Printf("Error receiving messages from %s: %v", processor.queueURL, err)
+				// Back off on error
+				select {
+				case <-s.ctx.Done():
+				case <-time.After(5 * time.Second):
+				}
+			} else if err == nil {
+				processor.stats.emptyReceives.Add(1)
+			}
+			continue
+		}
+		processor.stats.received.Add(int64(len(messages)))
+
+		for i, msg := range messages {
+			if i > 0 {
+				slots <- struct{}{}
+			}
+			inFlight.Add(1)
+			go func(msg broker.Message) {
+				defer inFlight.Done()
+				defer func() { <-slots }()
+				s.handleMessage(processor, &msg)
+			}(msg)
+		}
+	}
+}
+
+// handleMessage runs the processor's handler for one message, then queues the
+// message for deletion on success or schedules a retry on failure. It uses the
+// handler context, so a message received before shutdown is still acknowledged.
+func (s *FiberServer) handleMessage(processor *MessageProcessor, msg *broker.Message) {
+	ctx := s.handlerCtx
+
+	stop := s.extendVisibility(ctx, processor, msg)
+	err := processor.handler(ctx, msg)
+	stop()
+	processor.stats.touch()
+
+	if err != nil {
+		processor.stats.failed.Add(1)
+		log.Printf("Error processing message %s: %v", msg.ID, err)
+		// Retry later or dead-letter instead of deleting
+		s.handleFailure(ctx, processor, msg, err)
+		return
+	}
+	processor.stats.handled.Add(1)
+	processor.acks <- *msg
+}
+
+// deleteHandled deletes handled messages in batches, sending a
+// batch as soon as it is full or once deleteFlushInterval passes. It returns
+// after the processor closes acks and the last batch is sent.
+func (s *FiberServer) deleteHandled(processor *MessageProcessor) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(deleteFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]broker.Message, 0, maxBatchSize)
+	for {
+		select {
+		case msg, ok := <-processor.acks:
+			if !ok {
+				s.deleteBatch(processor, batch)
+				return
+			}
+			batch = append(batch, msg)
+			if len(batch) < maxBatchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+		s.deleteBatch(processor, batch)
+		batch = batch[:0]
+	}
+}
+
+// deleteBatch deletes up to 10 messages in one call. Receipts the broker
+// failed to delete are retried up to maxDeleteAttempts times; receipts it
+// rejected, such as an expired one, are not. A message that cannot be
+// deleted is redelivered later, which the handlers tolerate.
+func (s *FiberServer) deleteBatch(processor *MessageProcessor, batch []broker.Message) {
+	pending := make([]string, len(batch))
+	for i, msg := range batch {
+		pending[i] = msg.Receipt
+	}
+
+	for attempt := 1; len(pending) > 0; attempt++ {
+		processor.stats.deleteCalls.Add(1)
+		failures, err := s.consumer.Delete(s.handlerCtx, processor.queueURL, pending...)
+
+		var retry []string
+		if err != nil {
+			log.Printf("Failed to delete %d messages from %s: %v", len(pending), processor.name, err)
+			retry = pending
+		} else {
+			processor.stats.deleted.Add(int64(len(pending) - len(failures)))
+			for _, failure := range failures {
+				if !failure.Retryable {
+					processor.stats.deleteFailures.Add(1)
+					log.Printf("Failed to delete message from %s: %s", processor.name, failure.Code)
+					continue
+				}
+				retry = append(retry, failure.Receipt)
+			}
+		}
+
+		if len(retry) > 0 && (attempt == maxDeleteAttempts || s.handlerCtx.Err() != nil) {
+			processor.stats.deleteFailures.Add(int64(len(retry)))
+			log.Printf("Giving up deleting %d messages from %s after %d attempts", len(retry), processor.name, attempt)
+			return
+		}
+		pending = retry
+		if len(pending) > 0 {
+			select {
+			case <-s.handlerCtx.Done():
+			case <-time.After(time.Duration(attempt) * 200 * time.Millisecond):
+			}
+		}
+	}
+}
+
+// extendVisibility keeps msg hidden from other consumers while its handler runs
+// by renewing the visibility timeout every half period, or every half
+// processorStallTimeout if that is sooner. Each renewal counts as progress, so
+// a long handler does not make its processor look stalled. The returned function
+// stops the renewals and waits for any in progress, so it cannot override a
+// visibility change made after the handler returns.
+func (s *FiberServer) extendVisibility(ctx context.Context, processor *MessageProcessor, msg *broker.Message) (stop func()) {
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(min(processor.visibilityTimeout, processorStallTimeout) / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.consumer.ChangeVisibility(ctx, processor.queueURL, msg.Receipt, processor.visibilityTimeout); err != nil {
+					log.Printf("Failed to extend visibility of message %s: %v", msg.ID, err)
+					continue
+				}
+				processor.stats.touch()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"products-api/internal/broker"
+)
+
+func newDrainTestServer() *FiberServer {
+	ctx, cancel := context.WithCancel(context.Background())
+	handlerCtx, abort := context.WithCancel(context.Background())
+	return &FiberServer{ctx: ctx, cancel: cancel, handlerCtx: handlerCtx, abort: abort}
+}
+
+func TestStopMessageProcessorsWaitsForInFlightWork(t *testing.T) {
+	s := newDrainTestServer()
+
+	finished := make(chan struct{})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		<-s.ctx.Done()
+		// Still running after receiving stopped; the handler context stays usable
+		time.Sleep(20 * time.Millisecond)
+		if s.handlerCtx.Err() == nil {
+			close(finished)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.StopMessageProcessors(ctx); err != nil {
+		t.Fatalf("expected in-flight work to drain; got %v", err)
+	}
+	select {
+	case <-finished:
+	default:
+		t.Errorf("expected in-flight work to finish with a live handler context")
+	}
+}
+
+func TestStopMessageProcessorsAbortsAfterTimeout(t *testing.T) {
+	s := newDrainTestServer()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		<-s.handlerCtx.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.StopMessageProcessors(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded; got %v", err)
+	}
+	if s.handlerCtx.Err() == nil {
+		t.Errorf("expected handlers to be cancelled after the drain timeout")
+	}
+}
+
+func TestWithWorkers(t *testing.T) {
+	s := &FiberServer{}
+	s.AddMessageProcessor("http://localhost:4566/000000000000/Orders", nil, WithWorkers(8), WithWorkers(0))
+	if got := s.processors[0].workers; got != 8 {
+		t.Errorf("workers = %d; want 8", got)
+	}
+}
+
+func TestReceiveSettingOptions(t *testing.T) {
+	s := &FiberServer{}
+	s.AddMessageProcessor("http://localhost:4566/000000000000/Orders", nil,
+		WithMaxMessages(5), WithWaitTime(0), WithVisibilityTimeout(2*time.Minute))
+	// Values SQS would reject are ignored
+	s.AddMessageProcessor("http://localhost:4566/000000000000/Payments", nil,
+		WithMaxMessages(11), WithWaitTime(time.Minute), WithVisibilityTimeout(0))
+
+	tuned, defaults := s.processors[0], s.processors[1]
+	if tuned.maxMessages != 5 || tuned.waitTime != 0 || tuned.visibilityTimeout != 2*time.Minute {
+		t.Errorf("options not applied: %+v", tuned)
+	}
+	if defaults.maxMessages != defaultMaxMessages || defaults.waitTime != defaultWaitTime ||
+		defaults.visibilityTimeout != defaultVisibilityTimeout {
+		t.Errorf("invalid options should keep defaults: %+v", defaults)
+	}
+}
+
+func TestProcessorMetrics(t *testing.T) {
+	p := &MessageProcessor{name: "Orders"}
+	p.stats.receiveCalls.Add(4)
+	p.stats.received.Add(20)
+	p.stats.deleteCalls.Add(3)
+	p.stats.deleted.Add(18)
+
+	m := p.Metrics()
+	if m.Queue != "Orders" || m.MessagesPerReceive != 5 || m.MessagesPerDelete != 6 || m.DeleteCallsAvoided != 15 {
+		t.Errorf("unexpected metrics: %+v", m)
+	}
+	if empty := (&MessageProcessor{}).Metrics(); empty.MessagesPerReceive != 0 || empty.MessagesPerDelete != 0 {
+		t.Errorf("expected zero averages without calls: %+v", empty)
+	}
+}
+
+// waitFor polls cond until it holds or a second passes
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func depth(t *testing.T, b *broker.Memory, queue string) int {
+	t.Helper()
+	n, err := b.Depth(context.Background(), queue)
+	if err != nil {
+		t.Fatalf("depth of %s: %v", queue, err)
+	}
+	return n
+}
+
+func TestProcessQueueDeletesHandledMessages(t *testing.T) {
+	s := newDrainTestServer()
+	memory := broker.NewMemory()
+	s.SetBroker(memory)
+
+	var handled atomic.Int32
+	s.AddMessageProcessor("orders", func(ctx context.Context, msg *broker.Message) error {
+		handled.Add(1)
+		return nil
+	}, WithWorkers(3), WithWaitTime(20*time.Millisecond))
+	for i := 0; i < 5; i++ {
+		memory.Send(context.Background(), "orders", broker.OutgoingMessage{Body: `{}`})
+	}
+
+	s.StartMessageProcessors()
+	waitFor(t, "messages to be handled", func() bool { return handled.Load() == 5 })
+	if err := s.StopMessageProcessors(context.Background()); err != nil {
+		t.Fatalf("unexpected error stopping: %v", err)
+	}
+
+	if n := depth(t, memory, "orders"); n != 0 {
+		t.Errorf("expected handled messages to be deleted; %d left", n)
+	}
+	if m := s.processors[0].Metrics(); m.Deleted != 5 || m.DeleteCalls > 5 {
+		t.Errorf("unexpected metrics: %+v", m)
+	}
+}
+
+func TestProcessQueueDeadLettersPoisonMessages(t *testing.T) {
+	s := newDrainTestServer()
+	memory := broker.NewMemory()
+	s.SetBroker(memory)
+
+	s.AddMessageProcessor("orders", func(ctx context.Context, msg *broker.Message) error {
+		return fmt.Errorf("%w: bad order", ErrDeadLetter)
+	}, WithWaitTime(20*time.Millisecond), WithDeadLetterQueue("orders-dlq"))
+	memory.Send(context.Background(), "orders", broker.OutgoingMessage{Body: `{}`, Attributes: map[string]string{"type": "OrderCreated"}})
+
+	s.StartMessageProcessors()
+	waitFor(t, "message to be dead-lettered", func() bool { return depth(t, memory, "orders-dlq") == 1 })
+	if err := s.StopMessageProcessors(context.Background()); err != nil {
+		t.Fatalf("unexpected error stopping: %v", err)
+	}
+
+	if n := depth(t, memory, "orders"); n != 0 {
+		t.Errorf("expected the message to leave the source queue; %d left", n)
+	}
+	messages, _ := memory.Receive(context.Background(), "orders-dlq", broker.ReceiveOptions{MaxMessages: 1})
+	if len(messages) != 1 {
+		t.Fatalf("expected one dead-lettered message")
+	}
+	attrs := messages[0].Attributes
+	if attrs[attrSourceQueue] != "orders" || attrs[attrReceiveCount] != "1" || attrs["type"] != "OrderCreated" {
+		t.Errorf("unexpected dead-letter attributes: %v", attrs)
+	}
+}
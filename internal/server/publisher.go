@@ -0,0 +1,34 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"products-api/internal/broker"
+	"products-api/internal/models"
+	"products-api/internal/services"
+)
+
+// OutboxPublisher returns an EventPublisher that publishes outbox messages to
+// the given topic. The event type is sent as the "type" message attribute so
+// subscribers can filter on it. For FIFO topics the product ID is used as the
+// message group, preserving per-product ordering end to end.
+func (s *FiberServer) OutboxPublisher(topic string) services.EventPublisher {
+	fifo := strings.HasSuffix(topic, ".fifo")
+	return func(ctx context.Context, msg models.OutboxMessage) error {
+		if s.publisher == nil {
+			return errors.New("message broker not initialized")
+		}
+		out := broker.OutgoingMessage{
+			Body:       string(msg.Payload),
+			Attributes: map[string]string{typeAttribute: msg.EventType},
+		}
+		if fifo {
+			out.GroupID = msg.AggregateID
+			out.DeduplicationID = msg.EventID
+		}
+		_, err := s.publisher.Publish(ctx, topic, out)
+		return err
+	}
+}
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"products-api/internal/broker"
+)
+
+// typeAttribute is the message attribute that names the event type, both on
+// SNS notifications and on SQS messages
+const typeAttribute = "type"
+
+var (
+	// ErrUnknownEventType is returned for messages no handler is registered for
+	// when the router's fallback is FallbackError
+	ErrUnknownEventType = errors.New("unknown event type")
+	// ErrDeadLetter marks a failure that retrying cannot fix; the processor
+	// moves the message to its dead-letter queue without waiting for more deliveries
+	ErrDeadLetter = errors.New("message cannot be processed")
+)
+
+// FallbackPolicy decides what happens to messages whose event type has no handler
+type FallbackPolicy int
+
+const (
+	// FallbackError fails the message so it is retried like any other failure
+	FallbackError FallbackPolicy = iota
+	// FallbackDrop acknowledges and discards the message
+	FallbackDrop
+	// FallbackDeadLetter moves the message to the processor's dead-letter queue
+	FallbackDeadLetter
+)
+
+// ParseFallbackPolicy parses "error", "drop" or "dlq"
+func ParseFallbackPolicy(value string) (FallbackPolicy, error) {
+	switch strings.ToLower(value) {
+	case "error":
+		return FallbackError, nil
+	case "drop":
+		return FallbackDrop, nil
+	case "dlq":
+		return FallbackDeadLetter, nil
+	}
+	return 0, fmt.Errorf("invalid fallback policy %q: must be error, drop or dlq", value)
+}
+
+// MessageMeta describes where a routed message came from
+type MessageMeta struct {
+	// MessageID is stable across redeliveries: the SNS message ID for SNS
+	// notifications, otherwise the SQS message ID
+	MessageID string
+	EventType string
+	// TopicArn is set when the message arrived through SNS without raw delivery
+	TopicArn   string
+	Attributes map[string]string
+	Message    *broker.Message
+}
+
+// routeFunc decodes a payload and calls a typed handler
+type routeFunc func(ctx context.Context, payload json.RawMessage, meta MessageMeta) error
+
+// MessageRouter dispatches queue messages to handlers registered by event type.
+// Its Handle method is a MessageHandler.
+type MessageRouter struct {
+	routes      map[string]routeFunc
+	fallback    FallbackPolicy
+	defaultType string
+}
+
+// RouterOption configures a MessageRouter
+type RouterOption func(*MessageRouter)
+
+// WithFallback sets what happens to messages of unregistered types
+func WithFallback(policy FallbackPolicy) RouterOption {
+	return func(r *MessageRouter) {
+		r.fallback = policy
+	}
+}
+
+// WithDefaultType routes messages that carry no event type as eventType, for
+// publishers that only ever send one kind of event
+func WithDefaultType(eventType string) RouterOption {
+	return func(r *MessageRouter) {
+		r.defaultType = eventType
+	}
+}
+
+// NewMessageRouter creates a router with no handlers
+func NewMessageRouter(opts ...RouterOption) *MessageRouter {
+	r := &MessageRouter{routes: make(map[string]routeFunc)}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Register routes messages of eventType to handler, decoding their payload
+// into T first. A payload that does not decode fails with ErrDeadLetter.
+func Register[T any](r *MessageRouter, eventType string, handler func(ctx context.Context, event T, meta MessageMeta) error) {
+	r.routes[eventType] = func(ctx context.Context, payload json.RawMessage, meta MessageMeta) error {
+		var event T
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return fmt.Errorf("%w: decoding %s: %v", ErrDeadLetter, eventType, err)
+		}
+		return handler(ctx, event, meta)
+	}
+}
+
+// Handle decodes msg and calls the handler registered for its event type
+func (r *MessageRouter) Handle(ctx context.Context, msg *broker.Message) error {
+	payload, meta, err := decodeMessage(msg)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDeadLetter, err)
+	}
+	if meta.EventType == "" {
+		meta.EventType = r.defaultType
+	}
+
+	route, ok := r.routes[meta.EventType]
+	if !ok {
+		switch r.fallback {
+		case FallbackDrop:
+			log.Printf("Dropping message %s with unhandled event type %q", meta.MessageID, meta.EventType)
+			return nil
+		case FallbackDeadLetter:
+			return fmt.Errorf("%w: %w %q", ErrDeadLetter, ErrUnknownEventType, meta.EventType)
+		default:
+			return fmt.Errorf("%w %q", ErrUnknownEventType, meta.EventType)
+		}
+	}
+	return route(ctx, payload, meta)
+}
+
+// snsNotification is the body SQS receives from an SNS subscription without raw message delivery
+type snsNotification struct {
+	Type              string `json:"Type"`
+	MessageId         string `json:"MessageId"`
+	TopicArn          string `json:"TopicArn"`
+	Message           string `json:"Message"`
+	MessageAttributes map[string]struct {
+		Type  string `json:"Type"`
+		Value string `json:"Value"`
+	} `json:"MessageAttributes"`
+}
+
+// typedPayload picks the event type, and an enveloped event's data, out of a payload
+type typedPayload struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// decodeMessage unwraps an SNS notification if msg is one and resolves the
+// event type from the "type" message attribute or, failing that, a "type"
+// field in the payload. Payloads shaped like events.Envelope are unwrapped to
+// their data. With raw message delivery SNS attributes arrive as SQS message
+// attributes, so both subscription modes resolve the same way.
+func decodeMessage(msg *broker.Message) (json.RawMessage, MessageMeta, error) {
+	meta := MessageMeta{MessageID: msg.ID, Attributes: make(map[string]string), Message: msg}
+	for name, value := range msg.Attributes {
+		meta.Attributes[name] = value
+	}
+	if msg.Body == "" {
+		return nil, meta, errors.New("empty message body")
+	}
+
+	payload := json.RawMessage(msg.Body)
+	var notification snsNotification
+	if json.Unmarshal(payload, &notification) == nil && notification.Type == "Notification" && notification.TopicArn != "" {
+		payload = json.RawMessage(notification.Message)
+		meta.MessageID = notification.MessageId
+		meta.TopicArn = notification.TopicArn
+		for name, value := range notification.MessageAttributes {
+			meta.Attributes[name] = value.Value
+		}
+	}
+	if !json.Valid(payload) {
+		return nil, meta, errors.New("payload is not valid JSON")
+	}
+
+	meta.EventType = meta.Attributes[typeAttribute]
+	var typed typedPayload
+	if json.Unmarshal(payload, &typed) == nil && typed.Type != "" {
+		if meta.EventType == "" {
+			meta.EventType = typed.Type
+		}
+		if len(typed.Data) > 0 && typed.Type == meta.EventType {
+			payload = typed.Data
+		}
+	}
+	return payload, meta, nil
+}
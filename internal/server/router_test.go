@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"products-api/internal/broker"
+)
+
+type testEvent struct {
+	Name string `json:"name"`
+}
+
+func routedMessage(id, body string, attributes map[string]string) *broker.Message {
+	return &broker.Message{ID: id, Body: body, Attributes: attributes}
+}
+
+func TestMessageRouterDispatchesByType(t *testing.T) {
+	tests := []struct {
+		name   string
+		msg    *broker.Message
+		wantID string
+	}{
+		{
+			name:   "raw message with type attribute",
+			msg:    routedMessage("sqs-1", `{"name":"raw"}`, map[string]string{"type": "Greeted"}),
+			wantID: "sqs-1",
+		},
+		{
+			name:   "raw message with type field",
+			msg:    routedMessage("sqs-1", `{"type":"Greeted","name":"raw"}`, nil),
+			wantID: "sqs-1",
+		},
+		{
+			name:   "raw envelope",
+			msg:    routedMessage("sqs-1", `{"id":"e1","type":"Greeted","data":{"name":"raw"}}`, nil),
+			wantID: "sqs-1",
+		},
+		{
+			name: "SNS notification with message attribute",
+			msg: routedMessage("sqs-1", `{"Type":"Notification","MessageId":"sns-1","TopicArn":"arn:greetings",`+
+				`"Message":"{\"name\":\"raw\"}","MessageAttributes":{"type":{"Type":"String","Value":"Greeted"}}}`, nil),
+			wantID: "sns-1",
+		},
+		{
+			name: "SNS notification with envelope",
+			msg: routedMessage("sqs-1", `{"Type":"Notification","MessageId":"sns-1","TopicArn":"arn:greetings",`+
+				`"Message":"{\"type\":\"Greeted\",\"data\":{\"name\":\"raw\"}}"}`, nil),
+			wantID: "sns-1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := NewMessageRouter()
+			var got testEvent
+			var gotMeta MessageMeta
+			Register(router, "Greeted", func(ctx context.Context, event testEvent, meta MessageMeta) error {
+				got, gotMeta = event, meta
+				return nil
+			})
+
+			if err := router.Handle(context.Background(), tt.msg); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Name != "raw" || gotMeta.EventType != "Greeted" || gotMeta.MessageID != tt.wantID {
+				t.Errorf("got event %+v with meta %+v", got, gotMeta)
+			}
+		})
+	}
+}
+
+func TestMessageRouterFallback(t *testing.T) {
+	msg := routedMessage("sqs-1", `{"type":"Unknown"}`, nil)
+
+	if err := NewMessageRouter(WithFallback(FallbackDrop)).Handle(context.Background(), msg); err != nil {
+		t.Errorf("drop: expected the message to be acknowledged; got %v", err)
+	}
+
+	err := NewMessageRouter(WithFallback(FallbackDeadLetter)).Handle(context.Background(), msg)
+	if !errors.Is(err, ErrDeadLetter) || !errors.Is(err, ErrUnknownEventType) {
+		t.Errorf("dlq: expected ErrDeadLetter and ErrUnknownEventType; got %v", err)
+	}
+
+	err = NewMessageRouter().Handle(context.Background(), msg)
+	if !errors.Is(err, ErrUnknownEventType) || errors.Is(err, ErrDeadLetter) {
+		t.Errorf("error: expected a retryable ErrUnknownEventType; got %v", err)
+	}
+}
+
+func TestMessageRouterDefaultType(t *testing.T) {
+	router := NewMessageRouter(WithDefaultType("Greeted"))
+	called := false
+	Register(router, "Greeted", func(ctx context.Context, event testEvent, meta MessageMeta) error {
+		called = true
+		return nil
+	})
+	if err := router.Handle(context.Background(), routedMessage("sqs-1", `{"name":"untyped"}`, nil)); err != nil || !called {
+		t.Errorf("expected untyped message to reach the default handler; err %v", err)
+	}
+}
+
+func TestParseFallbackPolicy(t *testing.T) {
+	for value, want := range map[string]FallbackPolicy{"error": FallbackError, "DROP": FallbackDrop, "dlq": FallbackDeadLetter} {
+		if got, err := ParseFallbackPolicy(value); err != nil || got != want {
+			t.Errorf("ParseFallbackPolicy(%q) = %v, %v; want %v", value, got, err, want)
+		}
+	}
+	if _, err := ParseFallbackPolicy("ignore"); err == nil {
+		t.Errorf("expected an error for an unknown policy")
+	}
+}
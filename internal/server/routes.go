@@ -2,26 +2,29 @@ package server
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 
-	"github.com/aws/aws-sdk-go-v2/service/sns"
-	"github.com/aws/aws-sdk-go-v2/service/sqs"
-	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-)
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 
-func stringPtr(s string) *string {
-	return &s
-}
+	"products-api/internal/apperr"
+	"products-api/internal/broker"
+	"products-api/internal/events"
+)
 
 func (s *FiberServer) RegisterFiberRoutes() {
+	// Tag every request with an X-Request-ID, reported as the trace ID of errors
+	s.App.Use(requestid.New())
+
 	// Apply CORS middleware
 	s.App.Use(cors.New(cors.Config{
 		AllowOrigins:     "*",
 		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS,PATCH",
-		AllowHeaders:     "Accept,Authorization,Content-Type",
+		AllowHeaders:     "Accept,Authorization,Content-Type,If-Match,If-None-Match,X-Request-ID",
+		ExposeHeaders:    "ETag,X-Request-ID",
 		AllowCredentials: false, // credentials require explicit origins
 		MaxAge:           300,
 	}))
@@ -29,11 +32,21 @@ func (s *FiberServer) RegisterFiberRoutes() {
 	s.App.Get("/", s.HelloWorldHandler)
 
 	s.App.Get("/health", s.healthHandler)
+	s.App.Get("/livez", s.livenessHandler)
+	s.App.Get("/readyz", s.readinessHandler)
 
 	s.App.Post("/notify", s.notifyHandler)
 
 	s.App.Get("/events", s.eventsHandler)
 
+	if s.cfg.Features.AdminEndpoints {
+		admin := s.App.Group("/admin")
+		admin.Get("/processors", s.processorMetricsHandler)
+		admin.Get("/dlq", s.listDLQsHandler)
+		admin.Get("/dlq/:queue/messages", s.listDLQMessagesHandler)
+		admin.Get("/dlq/:queue/messages/:messageId", s.getDLQMessageHandler)
+		admin.Post("/dlq/:queue/redrive", s.redriveDLQHandler)
+	}
 }
 
 func (s *FiberServer) HelloWorldHandler(c *fiber.Ctx) error {
@@ -44,13 +57,18 @@ func (s *FiberServer) HelloWorldHandler(c *fiber.Ctx) error {
 	return c.JSON(resp)
 }
 
+// healthHandler reports database statistics, answering 503 when it is down
 func (s *FiberServer) healthHandler(c *fiber.Ctx) error {
-	return c.JSON(s.db.Health())
+	stats := s.db.Health()
+	if stats["status"] != statusUp {
+		c.Status(fiber.StatusServiceUnavailable)
+	}
+	return c.JSON(stats)
 }
 
 func (s *FiberServer) notifyHandler(c *fiber.Ctx) error {
-	if s.sns == nil {
-		return c.Status(500).JSON(fiber.Map{"error": "SNS client not initialized"})
+	if s.publisher == nil {
+		return errBrokerUnavailable
 	}
 
 	// Parse request body for topic ARN and message
@@ -59,87 +77,91 @@ func (s *FiberServer) notifyHandler(c *fiber.Ctx) error {
 		Message  string `json:"message"`
 	}
 	if err := c.BodyParser(&payload); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		return apperr.ErrInvalidBody
 	}
 
 	if payload.TopicArn == "" {
-		return c.Status(400).JSON(fiber.Map{"error": "topicArn is required"})
+		return apperr.New(apperr.BadRequest, "invalid_topic", "topicArn is required")
 	}
 
 	if payload.Message == "" {
 		payload.Message = "Default notification message"
 	}
 
-	_, err := s.sns.Publish(context.TODO(), &sns.PublishInput{
-		TopicArn: &payload.TopicArn,
-		Message:  &payload.Message,
-	})
+	_, err := s.publisher.Publish(c.Context(), payload.TopicArn, broker.OutgoingMessage{Body: payload.Message})
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return apperr.Wrap(apperr.BadGateway, "publish_failed", "failed to publish notification", err)
 	}
 
 	return c.JSON(fiber.Map{"message": "Notification sent"})
 }
 
+// eventsHandler peeks at up to 10 messages waiting on a consumed queue. The
+// messages are left on the queue for its processor, though each peek counts
+// as a delivery towards the queue's dead-letter threshold.
 func (s *FiberServer) eventsHandler(c *fiber.Ctx) error {
-	if s.sqs == nil {
-		return c.Status(500).JSON(fiber.Map{"error": "SQS client not initialized"})
+	if s.consumer == nil {
+		return errBrokerUnavailable
 	}
 
-	// Receive messages from queue
-	queueURL := "http://localstack:4566/000000000000/OrderCreatedTopic" // For LocalStack; use env for real AWS
+	// Peek at the queue named by ?queue=, or the first one consumed
+	if len(s.processors) == 0 {
+		return apperr.New(apperr.NotFound, "queue_not_found", "no queues configured")
+	}
+	processor := s.processors[0]
+	if name := c.Query("queue"); name != "" {
+		if processor = s.processorByName(name); processor == nil {
+			return apperr.New(apperr.NotFound, "queue_not_found", "unknown queue")
+		}
+	}
+	queueURL := processor.queueURL
 
-	result, err := s.sqs.ReceiveMessage(context.TODO(), &sqs.ReceiveMessageInput{
-		QueueUrl:            &queueURL,
-		MaxNumberOfMessages: 10,
-		WaitTimeSeconds:     0,
-	})
+	result, err := s.consumer.Receive(c.Context(), queueURL, broker.ReceiveOptions{MaxMessages: 10, Peek: true})
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return apperr.Wrap(apperr.BadGateway, "receive_failed", "failed to receive messages", err)
 	}
 
 	messages := []fiber.Map{}
-	for _, msg := range result.Messages {
+	for _, msg := range result {
 		messages = append(messages, fiber.Map{
-			"messageId": *msg.MessageId,
-			"body":      *msg.Body,
+			"messageId": msg.ID,
+			"body":      msg.Body,
 		})
-
-		// Delete the message after processing
-		_, delErr := s.sqs.DeleteMessage(context.TODO(), &sqs.DeleteMessageInput{
-			QueueUrl:      &queueURL,
-			ReceiptHandle: msg.ReceiptHandle,
-		})
-		if delErr != nil {
-			log.Printf("Failed to delete message: %v", delErr)
-		}
 	}
 
 	return c.JSON(fiber.Map{"events": messages})
 }
 
-// HandleProductMessage processes messages from the product queue
-func (s *FiberServer) HandleProductMessage(msg *types.Message) error {
-	log.Printf("Processing message: %s", *msg.Body)
+// RegisterMessageHandlers sets up the router behind RouteMessage. fallback
+// decides what happens to events nobody handles.
+func (s *FiberServer) RegisterMessageHandlers(fallback FallbackPolicy) {
+	s.router = NewMessageRouter(
+		WithFallback(fallback),
+		// The order service publishes OrderCreated without naming its type
+		WithDefaultType(events.OrderCreatedType),
+	)
+	Register(s.router, events.OrderCreatedType, s.HandleOrderCreated)
+}
 
-	// Parse SNS message format
-	var snsMessage struct {
-		Type      string `json:"Type"`
-		MessageId string `json:"MessageId"`
-		TopicArn  string `json:"TopicArn"`
-		Message   string `json:"Message"`
+// RouteMessage is the MessageHandler for queues whose events are dispatched by the router
+func (s *FiberServer) RouteMessage(ctx context.Context, msg *broker.Message) error {
+	if s.router == nil {
+		return errors.New("message handlers not registered")
 	}
+	return s.router.Handle(ctx, msg)
+}
 
-	if err := json.Unmarshal([]byte(*msg.Body), &snsMessage); err != nil {
-		log.Printf("Failed to parse SNS message: %v", err)
-		return err
+// HandleOrderCreated decrements stock for every line item of an order. A
+// returned error leaves the message on the queue so it is redelivered and
+// retried; redeliveries of a message that was already applied are
+// acknowledged without changing stock. Invalid orders are dead-lettered.
+func (s *FiberServer) HandleOrderCreated(ctx context.Context, order events.OrderCreated, meta MessageMeta) error {
+	if err := order.Validate(); err != nil {
+		log.Printf("Rejected OrderCreated message %s: %v", meta.MessageID, err)
+		return fmt.Errorf("%w: %w", ErrDeadLetter, err)
 	}
-
-	// Process based on message type or content
-	log.Printf("Received message from topic %s: %s", snsMessage.TopicArn, snsMessage.Message)
-
-	// Add your business logic here
-	// For example, update database, send notifications, etc.
-
-	return nil
+	if s.product == nil {
+		return errors.New("product service not initialized")
+	}
+	return s.product.ApplyOrder(ctx, meta.MessageID, order)
 }
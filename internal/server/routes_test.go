@@ -1,10 +1,16 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
 	"github.com/gofiber/fiber/v2"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"products-api/internal/broker"
 )
 
 func TestHandler(t *testing.T) {
@@ -37,3 +43,46 @@ func TestHandler(t *testing.T) {
 		t.Errorf("expected response body to be %v; got %v", expected, string(body))
 	}
 }
+
+func TestNotifyAndEventsHandlers(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	s := &FiberServer{App: app}
+	memory := broker.NewMemory()
+	memory.Subscribe(context.Background(), "orders-topic", "orders", true)
+	s.SetBroker(memory)
+	s.AddMessageProcessor("orders", nil)
+	app.Post("/notify", s.notifyHandler)
+	app.Get("/events", s.eventsHandler)
+
+	req := httptest.NewRequest("POST", "/notify", strings.NewReader(`{"topicArn":"orders-topic","message":"hello"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("error making request to server. Err: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK; got %v", resp.Status)
+	}
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/events", nil))
+	if err != nil {
+		t.Fatalf("error making request to server. Err: %v", err)
+	}
+	var result struct {
+		Events []struct {
+			Body string `json:"body"`
+		} `json:"events"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("error decoding response. Err: %v", err)
+	}
+	if len(result.Events) != 1 || result.Events[0].Body != "hello" {
+		t.Errorf("expected the published message; got %+v", result.Events)
+	}
+
+	// The message is still there for the queue's processor
+	received, _ := memory.Receive(context.Background(), s.processors[0].queueURL, broker.ReceiveOptions{MaxMessages: 10})
+	if len(received) != 1 || received[0].Body != "hello" {
+		t.Errorf("expected the peeked message to stay on the queue; got %+v", received)
+	}
+}
@@ -2,130 +2,116 @@ package server
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"sync"
-	"time"
 
-	"github.com/aws/aws-sdk-go-v2/config"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/sns"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
-	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 	"github.com/gofiber/fiber/v2"
 
+	"products-api/internal/broker"
+	"products-api/internal/config"
 	"products-api/internal/database"
 	"products-api/internal/services"
 )
 
 type FiberServer struct {
 	*fiber.App
+	cfg        *config.Config
 	db         database.Service
-	sns        *sns.Client
-	sqs        *sqs.Client
+	broker     broker.Broker
+	publisher  broker.Publisher
+	consumer   broker.Consumer
 	product    *services.ProductService
 	processors []*MessageProcessor
+	router     *MessageRouter
+	jobs       []*Job
 	wg         sync.WaitGroup
-	ctx        context.Context
-	cancel     context.CancelFunc
+	// ctx stops message receiving and background jobs
+	ctx    context.Context
+	cancel context.CancelFunc
+	// handlerCtx is passed to message handlers; it outlives ctx so in-flight
+	// messages can finish during shutdown and is cancelled by abort
+	handlerCtx context.Context
+	abort      context.CancelFunc
 }
 
-type MessageProcessor struct {
-	queueURL string
-	handler  func(msg *types.Message) error
-}
-
-func New() *FiberServer {
-	// Load AWS config
-	cfg, err := config.LoadDefaultConfig(context.TODO())
-	if err != nil {
-		log.Printf("unable to load AWS config: %v", err)
-		// Continue without SNS if config fails
-	}
-
-	dbSvc := database.New()
+// New creates the server from cfg on top of db, connecting to the broker cfg selects
+func New(cfg *config.Config, db database.Service) (*FiberServer, error) {
 	ctx, cancel := context.WithCancel(context.Background())
+	handlerCtx, abort := context.WithCancel(context.Background())
 	server := &FiberServer{
 		App: fiber.New(fiber.Config{
 			ServerHeader: "products-api",
 			AppName:      "products-api",
+			ErrorHandler: ErrorHandler,
 		}),
 
-		db:     dbSvc,
-		ctx:    ctx,
-		cancel: cancel,
+		cfg:        cfg,
+		db:         db,
+		ctx:        ctx,
+		cancel:     cancel,
+		handlerCtx: handlerCtx,
+		abort:      abort,
 	}
 
-	if cfg.Region != "" {
-		server.sns = sns.NewFromConfig(cfg)
-		server.sqs = sqs.NewFromConfig(cfg)
+	if cfg.AWS.Broker == "memory" {
+		log.Println("Using the in-memory message broker; messages are lost on restart")
+		server.SetBroker(broker.NewMemory())
+		return server, nil
 	}
-
-	return server
-}
-
-// AddMessageProcessor adds a new message processor for a queue
-func (s *FiberServer) AddMessageProcessor(queueURL string, handler func(msg *types.Message) error) {
-	processor := &MessageProcessor{
-		queueURL: queueURL,
-		handler:  handler,
+	if cfg.AWS.Region == "" {
+		// Nothing needs the broker, see config.Config.validate
+		return server, nil
 	}
-	s.processors = append(s.processors, processor)
-}
 
-// StartMessageProcessors starts all registered message processors
-func (s *FiberServer) StartMessageProcessors() {
-	for _, processor := range s.processors {
-		s.wg.Add(1)
-		go s.processQueue(processor)
+	opts := []func(*awsconfig.LoadOptions) error{awsconfig.WithRegion(cfg.AWS.Region)}
+	if cfg.AWS.EndpointURL != "" {
+		opts = append(opts, awsconfig.WithBaseEndpoint(cfg.AWS.EndpointURL))
 	}
-}
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.TODO(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS config: %w", err)
+	}
+	server.SetBroker(broker.NewAWS(sns.NewFromConfig(awsCfg), sqs.NewFromConfig(awsCfg)))
 
-// StopMessageProcessors stops all message processors
-func (s *FiberServer) StopMessageProcessors() {
-	s.cancel()
-	s.wg.Wait()
+	return server, nil
 }
 
-// processQueue continuously processes messages from a queue
-func (s *FiberServer) processQueue(processor *MessageProcessor) {
-	defer s.wg.Done()
-
-	for {
-		select {
-		case <-s.ctx.Done():
-			log.Printf("Stopping message processor for queue: %s", processor.queueURL)
-			return
-		default:
-			// Receive messages
-			result, err := s.sqs.ReceiveMessage(s.ctx, &sqs.ReceiveMessageInput{
-				QueueUrl:            &processor.queueURL,
-				MaxNumberOfMessages: 10,
-				WaitTimeSeconds:     20, // Long polling
-				VisibilityTimeout:   30, // 30 seconds to process
-			})
+// SetBroker sets the broker used to publish events and consume queues
+func (s *FiberServer) SetBroker(b broker.Broker) {
+	s.broker = b
+	s.publisher = b
+	s.consumer = b
+}
 
-			if err != nil {
-				log.Printf("Error receiving messages from %s: %v", processor.queueURL, err)
-				time.Sleep(5 * time.Second) // Back off on error
-				continue
-			}
+// SetProductService sets the service used by message handlers to update products
+func (s *FiberServer) SetProductService(product *services.ProductService) {
+	s.product = product
+}
 
-			// Process messages
-			for _, msg := range result.Messages {
-				if err := processor.handler(&msg); err != nil {
-					log.Printf("Error processing message %s: %v", *msg.MessageId, err)
-					// Don't delete the message if processing failed
-					continue
-				}
+// StopMessageProcessors stops receiving messages and background jobs, then
+// waits for in-flight messages to finish. If ctx ends first, running handlers
+// are cancelled and ctx's error is returned; their messages become visible on
+// the queue again once their visibility timeout runs out.
+func (s *FiberServer) StopMessageProcessors(ctx context.Context) error {
+	s.cancel()
 
-				// Delete the message after successful processing
-				_, delErr := s.sqs.DeleteMessage(s.ctx, &sqs.DeleteMessageInput{
-					QueueUrl:      &processor.queueURL,
-					ReceiptHandle: msg.ReceiptHandle,
-				})
-				if delErr != nil {
-					log.Printf("Failed to delete message %s: %v", *msg.MessageId, delErr)
-				}
-			}
+	drained := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		if s.abort != nil {
+			s.abort()
 		}
+		return ctx.Err()
 	}
 }
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"encoding/json"
+	"products-api/internal/apperr"
+)
+
+// ErrInvalidPatch is returned when a merge patch body is not a JSON object.
+var ErrInvalidPatch = apperr.New(apperr.BadRequest, "invalid_patch", "merge patch must be a JSON object")
+
+// applyMergePatch applies an RFC 7386 JSON merge patch to the JSON document doc.
+func applyMergePatch(doc, patch []byte) ([]byte, error) {
+	var target map[string]any
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+
+	var p any
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, ErrInvalidPatch
+	}
+	patchObj, ok := p.(map[string]any)
+	if !ok {
+		return nil, ErrInvalidPatch
+	}
+
+	return json.Marshal(mergeObjects(target, patchObj))
+}
+
+func mergeObjects(target, patch map[string]any) map[string]any {
+	if target == nil {
+		target = map[string]any{}
+	}
+	for key, value := range patch {
+		if value == nil {
+			delete(target, key)
+			continue
+		}
+		if patchChild, ok := value.(map[string]any); ok {
+			targetChild, _ := target[key].(map[string]any)
+			target[key] = mergeObjects(targetChild, patchChild)
+			continue
+		}
+		target[key] = value
+	}
+	return target
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyMergePatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		patch    string
+		expected string
+		err      error
+	}{
+		{name: "replaces a field", doc: `{"name":"a","price":1}`, patch: `{"name":"b"}`, expected: `{"name":"b","price":1}`},
+		{name: "null removes a field", doc: `{"name":"a","seller_id":"s1"}`, patch: `{"seller_id":null}`, expected: `{"name":"a"}`},
+		{name: "merges nested objects", doc: `{"meta":{"a":1,"b":2}}`, patch: `{"meta":{"b":null,"c":3}}`, expected: `{"meta":{"a":1,"c":3}}`},
+		{name: "rejects non-object patches", doc: `{"name":"a"}`, patch: `["name"]`, err: ErrInvalidPatch},
+		{name: "rejects malformed patches", doc: `{"name":"a"}`, patch: `{`, err: ErrInvalidPatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := applyMergePatch([]byte(tt.doc), []byte(tt.patch))
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.expected, string(result))
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"products-api/internal/apperr"
+	"products-api/internal/events"
+	"products-api/internal/models"
+	"products-api/internal/repository"
+	"products-api/internal/validation"
+)
+
+// ErrInvalidProduct is returned when an order is placed without a product ID.
+var ErrInvalidProduct = apperr.New(apperr.BadRequest, "invalid_product", "product_id is required")
+
+// OrderService places orders against product stock
+type OrderService struct {
+	tx       *repository.Transactor
+	products *repository.ProductRepository
+	orders   *repository.OrderRepository
+	outbox   *repository.OutboxRepository
+}
+
+func NewOrderService(tx *repository.Transactor, products *repository.ProductRepository, orders *repository.OrderRepository, outbox *repository.OutboxRepository) *OrderService {
+	return &OrderService{tx: tx, products: products, orders: orders, outbox: outbox}
+}
+
+// PlaceOrder takes quantity units of a product from stock and records the
+// order in the same transaction, priced at the product's current price. It
+// returns repository.ErrInsufficientStock if fewer units are available and
+// repository.ErrProductNotFound if the product does not exist. A quantity that
+// makes the total price too large to store is a validation error on quantity.
+func (s *OrderService) PlaceOrder(ctx context.Context, productID string, quantity int) (*models.Order, error) {
+	if quantity <= 0 {
+		return nil, ErrInvalidQuantity
+	}
+	if productID == "" {
+		return nil, ErrInvalidProduct
+	}
+
+	order := &models.Order{ProductID: productID, Quantity: quantity}
+	order.SetID()
+
+	err := s.tx.WithinTx(ctx, func(tx *sql.Tx) error {
+		// The decrement locks the product row, so the price read with it is
+		// the one the order is charged
+		product, err := s.products.WithTx(tx).UpdateProductCount(ctx, productID, quantity)
+		if err != nil {
+			return err
+		}
+		order.TotalPrice, err = product.Price.Mul(int64(quantity))
+		if err != nil || order.TotalPrice.Units() > models.MaxOrderTotalUnits {
+			return validation.Errors{{Field: "quantity", Message: "makes the total price too large"}}
+		}
+		if err := s.orders.WithTx(tx).Create(ctx, order); err != nil {
+			return err
+		}
+		return recordStockChange(ctx, s.outbox.WithTx(tx), product, -quantity, product.AvailableQuantity+quantity, events.StockReasonOrder)
+	})
+	if err != nil {
+		log.Printf("Error placing order for %d units of product %s: %v", quantity, productID, err)
+		return nil, err
+	}
+	log.Printf("Placed order %s for %d units of product %s", order.ID, quantity, productID)
+	return order, nil
+}
+
+// GetOrder retrieves an order by ID
+func (s *OrderService) GetOrder(ctx context.Context, id string) (*models.Order, error) {
+	return s.orders.GetByID(ctx, id)
+}
+
+// ListOrders retrieves one page of orders matching the query
+func (s *OrderService) ListOrders(ctx context.Context, query repository.OrderQuery) (*repository.OrderPage, error) {
+	page, err := s.orders.List(ctx, query)
+	if err != nil {
+		log.Printf("Error retrieving orders: %v", err)
+		return nil, err
+	}
+	return page, nil
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"products-api/internal/events"
+	"products-api/internal/money"
+	"products-api/internal/repository"
+	"products-api/internal/validation"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var orderColumnNames = []string{"id", "product_id", "quantity", "total_price", "currency", "created_at", "updated_at"}
+
+func newOrderService(t *testing.T) (*OrderService, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+	t.Cleanup(func() {
+		assert.NoError(t, mock.ExpectationsWereMet())
+		db.Close()
+	})
+	service := NewOrderService(repository.NewTransactor(db), repository.NewProductRepository(db), repository.NewOrderRepository(db), repository.NewOutboxRepository(db))
+	return service, mock
+}
+
+func TestPlaceOrderDecrementsStockAndPricesOrder(t *testing.T) {
+	service, mock := newOrderService(t)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE products SET quantity = quantity - \\$1").WithArgs(3, "p1").
+		WillReturnRows(sqlmock.NewRows(productColumnNames).AddRow("p1", "Product", 9.99, "", 7, now, now, nil, 0, "USD", 1))
+	mock.ExpectQuery("INSERT INTO orders").WithArgs(sqlmock.AnyArg(), "p1", 3, "29.97", money.Currency("USD")).
+		WillReturnRows(sqlmock.NewRows(orderColumnNames).AddRow("o1", "p1", 3, "29.970", "USD", now, now))
+	expectOutboxEvent(mock, "p1", events.StockChangedType)
+	mock.ExpectCommit()
+
+	order, err := service.PlaceOrder(context.Background(), "p1", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "o1", order.ID)
+	assert.Equal(t, money.New(2997, "USD"), order.TotalPrice)
+}
+
+func TestPlaceOrderRollsBackWhenStockIsInsufficient(t *testing.T) {
+	service, mock := newOrderService(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE products SET quantity = quantity - \\$1").WithArgs(20, "p1").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT EXISTS").WithArgs("p1").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	_, err := service.PlaceOrder(context.Background(), "p1", 20)
+	assert.ErrorIs(t, err, repository.ErrInsufficientStock)
+}
+
+func TestPlaceOrderRejectsTotalsTooLargeToStore(t *testing.T) {
+	service, mock := newOrderService(t)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE products SET quantity = quantity - \\$1").WithArgs(5000, "p1").
+		WillReturnRows(sqlmock.NewRows(productColumnNames).AddRow("p1", "Product", "99999999.99", "", 0, now, now, nil, 0, "USD", 1))
+	mock.ExpectRollback()
+
+	_, err := service.PlaceOrder(context.Background(), "p1", 5000)
+	var fields validation.Errors
+	assert.ErrorAs(t, err, &fields)
+	assert.Equal(t, "quantity", fields[0].Field)
+}
+
+func TestPlaceOrderValidatesInput(t *testing.T) {
+	service, _ := newOrderService(t)
+
+	_, err := service.PlaceOrder(context.Background(), "p1", 0)
+	assert.ErrorIs(t, err, ErrInvalidQuantity)
+	_, err = service.PlaceOrder(context.Background(), "", 1)
+	assert.ErrorIs(t, err, ErrInvalidProduct)
+}
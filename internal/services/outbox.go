@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"products-api/internal/events"
+	"products-api/internal/models"
+	"products-api/internal/repository"
+	"time"
+)
+
+// EventPublisher delivers one outbox message to the message broker.
+type EventPublisher func(ctx context.Context, msg models.OutboxMessage) error
+
+const (
+	relayBatchSize   = 100
+	relayBaseBackoff = time.Second
+	relayMaxBackoff  = 5 * time.Minute
+)
+
+// recordEvent writes a domain event to the outbox. outbox must be bound to the
+// transaction that makes the change the event describes.
+func recordEvent(ctx context.Context, outbox *repository.OutboxRepository, eventType, aggregateID string, data any) error {
+	msg, err := events.NewOutboxMessage(eventType, aggregateID, data)
+	if err != nil {
+		return err
+	}
+	return outbox.Add(ctx, msg)
+}
+
+// recordStockChange writes a StockChanged event for a product whose on-hand
+// quantity changed by delta, followed by OutOfStock when the change took its
+// available quantity from previousAvailable down to zero.
+func recordStockChange(ctx context.Context, outbox *repository.OutboxRepository, product *models.Product, delta, previousAvailable int, reason string) error {
+	if delta != 0 {
+		err := recordEvent(ctx, outbox, events.StockChangedType, product.ID, events.StockChanged{
+			ProductID:         product.ID,
+			Quantity:          product.Quantity,
+			ReservedQuantity:  product.ReservedQuantity,
+			AvailableQuantity: product.AvailableQuantity,
+			Delta:             delta,
+			Reason:            reason,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if product.AvailableQuantity <= 0 && previousAvailable > 0 {
+		return recordEvent(ctx, outbox, events.OutOfStockType, product.ID, events.OutOfStock{ProductID: product.ID})
+	}
+	return nil
+}
+
+// OutboxRelay publishes events from the outbox to the message broker
+type OutboxRelay struct {
+	tx      *repository.Transactor
+	outbox  *repository.OutboxRepository
+	publish EventPublisher
+}
+
+func NewOutboxRelay(tx *repository.Transactor, outbox *repository.OutboxRepository, publish EventPublisher) *OutboxRelay {
+	return &OutboxRelay{tx: tx, outbox: outbox, publish: publish}
+}
+
+// RelayPending publishes due outbox messages until none are left and returns
+// how many were sent. Each pass claims only the oldest message of every
+// product, so passes repeat until a claim comes back empty; a product with a
+// backlog is drained within one call rather than one message per tick. A
+// message that fails to publish is retried with exponential backoff, and later
+// events for the same product wait behind it.
+func (r *OutboxRelay) RelayPending(ctx context.Context) (int, error) {
+	sent := 0
+	for {
+		claimed := 0
+		err := r.tx.WithinTx(ctx, func(tx *sql.Tx) error {
+			outbox := r.outbox.WithTx(tx)
+			messages, err := outbox.ClaimPending(ctx, time.Now(), relayBatchSize)
+			if err != nil {
+				return err
+			}
+			claimed = len(messages)
+
+			for _, msg := range messages {
+				if err := r.publish(ctx, msg); err != nil {
+					log.Printf("Failed to publish %s event %s (attempt %d): %v", msg.EventType, msg.EventID, msg.Attempts+1, err)
+					if err := outbox.MarkFailed(ctx, msg.ID, err.Error(), time.Now().Add(relayBackoff(msg.Attempts))); err != nil {
+						return err
+					}
+					continue
+				}
+				if err := outbox.MarkSent(ctx, msg.ID); err != nil {
+					return err
+				}
+				sent++
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Error relaying outbox messages: %v", err)
+			return sent, err
+		}
+		// Failed messages are pushed into the future, so this ends even when nothing could be sent
+		if claimed == 0 {
+			return sent, nil
+		}
+	}
+}
+
+// PurgeSent removes published outbox messages older than retention.
+func (r *OutboxRelay) PurgeSent(ctx context.Context, retention time.Duration) (int64, error) {
+	purged, err := r.outbox.DeleteSentBefore(ctx, time.Now().Add(-retention))
+	if err != nil {
+		log.Printf("Error purging sent outbox messages: %v", err)
+		return 0, err
+	}
+	return purged, nil
+}
+
+// relayBackoff returns the delay before retrying a message that has already
+// failed attempts times.
+func relayBackoff(attempts int) time.Duration {
+	backoff := relayBaseBackoff
+	for i := 0; i < attempts && backoff < relayMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, relayMaxBackoff)
+}
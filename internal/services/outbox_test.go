@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"products-api/internal/models"
+	"products-api/internal/repository"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var outboxColumnNames = []string{"id", "event_id", "aggregate_id", "event_type", "payload", "attempts", "created_at"}
+
+func TestRelayPendingMarksSentAndRetriesFailuresUntilDrained(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	var published []string
+	relay := NewOutboxRelay(repository.NewTransactor(db), repository.NewOutboxRepository(db), func(ctx context.Context, msg models.OutboxMessage) error {
+		if msg.AggregateID == "p2" {
+			return errors.New("broker unavailable")
+		}
+		published = append(published, msg.EventID)
+		return nil
+	})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .* FROM outbox o .* NOT EXISTS .* FOR UPDATE SKIP LOCKED").
+		WillReturnRows(sqlmock.NewRows(outboxColumnNames).
+			AddRow(1, "e1", "p1", "StockChanged", []byte(`{}`), 0, time.Now()).
+			AddRow(2, "e2", "p2", "StockChanged", []byte(`{}`), 2, time.Now()))
+	mock.ExpectExec("UPDATE outbox SET sent_at = NOW\\(\\)").WithArgs(int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE outbox SET attempts = attempts \\+ 1").WithArgs("broker unavailable", sqlmock.AnyArg(), int64(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	// The next event of p1 only becomes claimable once e1 is sent
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .* FROM outbox o").
+		WillReturnRows(sqlmock.NewRows(outboxColumnNames).AddRow(3, "e3", "p1", "StockChanged", []byte(`{}`), 0, time.Now()))
+	mock.ExpectExec("UPDATE outbox SET sent_at = NOW\\(\\)").WithArgs(int64(3)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .* FROM outbox o").WillReturnRows(sqlmock.NewRows(outboxColumnNames))
+	mock.ExpectCommit()
+
+	sent, err := relay.RelayPending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, sent)
+	assert.Equal(t, []string{"e1", "e3"}, published)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRelayBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, relayBackoff(0))
+	assert.Equal(t, 8*time.Second, relayBackoff(3))
+	assert.Equal(t, relayMaxBackoff, relayBackoff(50))
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"products-api/internal/apperr"
+	"products-api/internal/models"
+	"products-api/internal/money"
+	"products-api/internal/repository"
+	"products-api/internal/validation"
+	"strings"
+	"time"
+)
+
+// Sources of the price in a PriceQuote.
+const (
+	// PriceSourceProduct is the product's own price, already in the requested currency
+	PriceSourceProduct = "product"
+	// PriceSourcePriceList is a price list entry for the requested currency
+	PriceSourcePriceList = "price_list"
+	// PriceSourceExchangeRate is the product's own price converted at an exchange rate
+	PriceSourceExchangeRate = "exchange_rate"
+)
+
+// ErrCurrencyUnavailable is returned when a product has no price in the
+// requested currency and no exchange rate converts its own price to it. The
+// returned error names the missing currency pair and wraps it.
+var ErrCurrencyUnavailable = apperr.New(apperr.BadRequest, "currency_unavailable", "no price or exchange rate for the requested currency")
+
+// currencyUnavailable is ErrCurrencyUnavailable for the pair from, to
+func currencyUnavailable(from, to money.Currency) error {
+	message := fmt.Sprintf("no price in %s and no exchange rate from %s to %s", to, from, to)
+	return apperr.Wrap(ErrCurrencyUnavailable.Kind, ErrCurrencyUnavailable.Code, message, ErrCurrencyUnavailable)
+}
+
+// errConvertedPriceTooLarge is returned when converting a product's price
+// leaves the range of an amount
+var errConvertedPriceTooLarge = validation.Errors{{Field: "currency", Message: "converts the price to an amount too large"}}
+
+// PriceQuote is a product's price in a requested currency and where it came from.
+type PriceQuote struct {
+	Price     money.Money `json:"price"`
+	Source    string      `json:"source"`
+	PriceList string      `json:"price_list,omitempty"`
+	// ExchangeRate is the rate the product's own price was converted at.
+	// Rates stored for the opposite direction are shown inverted, rounded to
+	// models.MaxRateDecimals places; the conversion itself uses the exact inverse.
+	ExchangeRate *models.ExchangeRate `json:"exchange_rate,omitempty"`
+}
+
+// PricingService prices products in other currencies from price lists and
+// exchange rates, and manages both.
+type PricingService struct {
+	prices *repository.PriceRepository
+	rates  *repository.ExchangeRateRepository
+	now    func() time.Time
+}
+
+func NewPricingService(prices *repository.PriceRepository, rates *repository.ExchangeRateRepository) *PricingService {
+	return &PricingService{prices: prices, rates: rates, now: time.Now}
+}
+
+// Quote prices products in currency, returning one quote per product in the
+// same order. A price on priceList wins, then one on the default price list,
+// then the product's own price if it is in currency, and last the product's
+// own price converted at the exchange rate in effect now. The quote is nil for
+// a product none of these price, or whose converted price is too large, so one
+// such product cannot fail a whole listing.
+func (s *PricingService) Quote(ctx context.Context, products []models.Product, currency money.Currency, priceList string) ([]*PriceQuote, error) {
+	quotes, _, err := s.quote(ctx, products, currency, priceList)
+	return quotes, err
+}
+
+// QuoteProduct prices one product like Quote. It returns an error naming the
+// currency pair when the product cannot be priced in currency, and a
+// validation error when its converted price is too large.
+func (s *PricingService) QuoteProduct(ctx context.Context, product models.Product, currency money.Currency, priceList string) (PriceQuote, error) {
+	quotes, failures, err := s.quote(ctx, []models.Product{product}, currency, priceList)
+	if err != nil {
+		return PriceQuote{}, err
+	}
+	if quotes[0] == nil {
+		return PriceQuote{}, failures[0]
+	}
+	return *quotes[0], nil
+}
+
+// quote implements Quote, also returning why each product without a quote
+// could not be priced
+func (s *PricingService) quote(ctx context.Context, products []models.Product, currency money.Currency, priceList string) ([]*PriceQuote, []error, error) {
+	if priceList == "" {
+		priceList = models.DefaultPriceList
+	}
+	lists := []string{priceList}
+	if priceList != models.DefaultPriceList {
+		lists = append(lists, models.DefaultPriceList)
+	}
+	ids := make([]string, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+	}
+
+	entries, err := s.prices.Find(ctx, ids, lists, currency)
+	if err != nil {
+		return nil, nil, err
+	}
+	listed := make(map[string]map[string]money.Money, len(entries))
+	for _, entry := range entries {
+		if listed[entry.ProductID] == nil {
+			listed[entry.ProductID] = make(map[string]money.Money)
+		}
+		listed[entry.ProductID][entry.PriceList] = entry.Price
+	}
+
+	now := s.now()
+	// A nil rate records a currency known to have none
+	rates := make(map[money.Currency]*appliedRate)
+	quotes := make([]*PriceQuote, len(products))
+	failures := make([]error, len(products))
+	for i, product := range products {
+		if quote, ok := listedQuote(listed[product.ID], lists); ok {
+			quotes[i] = &quote
+			continue
+		}
+		if product.Price.Currency == currency {
+			quotes[i] = &PriceQuote{Price: product.Price, Source: PriceSourceProduct}
+			continue
+		}
+
+		rate, ok := rates[product.Price.Currency]
+		if !ok {
+			rate, err = s.rate(ctx, product.Price.Currency, currency, now)
+			if err != nil && !errors.Is(err, ErrCurrencyUnavailable) {
+				return nil, nil, err
+			}
+			rates[product.Price.Currency] = rate
+		}
+		if rate == nil {
+			failures[i] = currencyUnavailable(product.Price.Currency, currency)
+			continue
+		}
+		price, err := product.Price.Convert(currency, rate.value)
+		if err != nil {
+			log.Printf("Error converting price of product %s: %v", product.ID, err)
+			failures[i] = errConvertedPriceTooLarge
+			continue
+		}
+		quotes[i] = &PriceQuote{Price: price, Source: PriceSourceExchangeRate, ExchangeRate: &rate.ExchangeRate}
+	}
+	return quotes, failures, nil
+}
+
+// listedQuote returns the price on the first of lists that has one
+func listedQuote(prices map[string]money.Money, lists []string) (PriceQuote, bool) {
+	for _, list := range lists {
+		if price, ok := prices[list]; ok {
+			return PriceQuote{Price: price, Source: PriceSourcePriceList, PriceList: list}, true
+		}
+	}
+	return PriceQuote{}, false
+}
+
+// appliedRate is an exchange rate from one currency to another and its value
+type appliedRate struct {
+	models.ExchangeRate
+	value *big.Rat
+}
+
+// rate returns the exchange rate from one currency to another in effect at
+// the given time, inverting a rate stored for the opposite direction.
+func (s *PricingService) rate(ctx context.Context, from, to money.Currency, at time.Time) (*appliedRate, error) {
+	stored, err := s.rates.Effective(ctx, from, to, at)
+	if errors.Is(err, repository.ErrExchangeRateNotFound) {
+		return nil, currencyUnavailable(from, to)
+	}
+	if err != nil {
+		return nil, err
+	}
+	value, err := money.ParseRate(stored.Rate)
+	if err != nil {
+		return nil, err
+	}
+
+	rate := &appliedRate{ExchangeRate: *stored, value: value}
+	if stored.Base != from {
+		rate.value = new(big.Rat).Inv(value)
+		rate.Base, rate.Quote = from, to
+		rate.Rate = strings.TrimRight(strings.TrimRight(rate.value.FloatString(models.MaxRateDecimals), "0"), ".")
+	}
+	return rate, nil
+}
+
+// SetPrice creates or replaces a price list entry. It returns
+// repository.ErrProductNotFound if the product does not exist.
+func (s *PricingService) SetPrice(ctx context.Context, price *models.ProductPrice) error {
+	if price.PriceList == "" {
+		price.PriceList = models.DefaultPriceList
+	}
+	if err := price.Validate(); err != nil {
+		return err
+	}
+	if err := s.prices.Set(ctx, price); err != nil {
+		log.Printf("Error setting %s price of product %s: %v", price.Price.Currency, price.ProductID, err)
+		return err
+	}
+	return nil
+}
+
+// ListPrices returns every price list entry of a product.
+func (s *PricingService) ListPrices(ctx context.Context, productID string) ([]models.ProductPrice, error) {
+	return s.prices.ListByProduct(ctx, productID)
+}
+
+// DeletePrice removes a price list entry. It returns repository.ErrPriceNotFound
+// if there is none.
+func (s *PricingService) DeletePrice(ctx context.Context, productID, priceList string, currency money.Currency) error {
+	if priceList == "" {
+		priceList = models.DefaultPriceList
+	}
+	return s.prices.Delete(ctx, productID, priceList, currency)
+}
+
+// SetExchangeRate stores an exchange rate, taking effect now unless it names
+// a time.
+func (s *PricingService) SetExchangeRate(ctx context.Context, rate *models.ExchangeRate) error {
+	if rate.EffectiveAt.IsZero() {
+		rate.EffectiveAt = s.now()
+	}
+	if err := rate.Validate(); err != nil {
+		return err
+	}
+	if err := s.rates.Set(ctx, rate); err != nil {
+		log.Printf("Error setting %s/%s exchange rate: %v", rate.Base, rate.Quote, err)
+		return err
+	}
+	return nil
+}
+
+// ListExchangeRates returns the stored exchange rates, optionally only those
+// from base or to quote.
+func (s *PricingService) ListExchangeRates(ctx context.Context, base, quote money.Currency) ([]models.ExchangeRate, error) {
+	return s.rates.List(ctx, base, quote)
+}
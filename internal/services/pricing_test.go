@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"products-api/internal/apperr"
+	"products-api/internal/models"
+	"products-api/internal/money"
+	"products-api/internal/repository"
+	"products-api/internal/validation"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	productPriceColumnNames = []string{"product_id", "price_list", "currency", "amount", "created_at", "updated_at"}
+	exchangeRateColumnNames = []string{"base_currency", "quote_currency", "rate", "effective_at"}
+)
+
+func newPricingService(t *testing.T) (*PricingService, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+	t.Cleanup(func() {
+		assert.NoError(t, mock.ExpectationsWereMet())
+		db.Close()
+	})
+	service := NewPricingService(repository.NewPriceRepository(db), repository.NewExchangeRateRepository(db))
+	return service, mock
+}
+
+func pricedProduct(id string, price money.Money) models.Product {
+	return models.Product{BaseModel: models.BaseModel{ID: id}, Name: id, Price: price}
+}
+
+func TestQuotePrefersPriceListsThenOwnPriceThenExchangeRate(t *testing.T) {
+	service, mock := newPricingService(t)
+	now := time.Now()
+	service.now = func() time.Time { return now }
+	products := []models.Product{
+		pricedProduct("listed", money.New(1000, "USD")),
+		pricedProduct("default-listed", money.New(1000, "USD")),
+		pricedProduct("in-eur", money.New(1250, "EUR")),
+		pricedProduct("converted", money.New(1999, "USD")),
+		pricedProduct("also-converted", money.New(500, "USD")),
+	}
+
+	mock.ExpectQuery("SELECT .* FROM product_prices WHERE currency = \\$1 AND product_id IN \\(\\$2, \\$3, \\$4, \\$5, \\$6\\) AND price_list IN \\(\\$7, \\$8\\)").
+		WithArgs(money.Currency("EUR"), "listed", "default-listed", "in-eur", "converted", "also-converted", "eu-retail", models.DefaultPriceList).
+		WillReturnRows(sqlmock.NewRows(productPriceColumnNames).
+			AddRow("listed", "default", "EUR", "8.000", now, now).
+			AddRow("listed", "eu-retail", "EUR", "9.500", now, now).
+			AddRow("default-listed", "default", "EUR", "8.000", now, now))
+	// The rate is looked up once for every product priced in USD
+	mock.ExpectQuery("SELECT .* FROM exchange_rates").WithArgs(money.Currency("USD"), money.Currency("EUR"), now).
+		WillReturnRows(sqlmock.NewRows(exchangeRateColumnNames).AddRow("USD", "EUR", "0.9200000000", now.Add(-time.Hour)))
+
+	quotes, err := service.Quote(context.Background(), products, "EUR", "eu-retail")
+	assert.NoError(t, err)
+	assert.Equal(t, PriceQuote{Price: money.New(950, "EUR"), Source: PriceSourcePriceList, PriceList: "eu-retail"}, *quotes[0])
+	assert.Equal(t, PriceQuote{Price: money.New(800, "EUR"), Source: PriceSourcePriceList, PriceList: models.DefaultPriceList}, *quotes[1])
+	assert.Equal(t, PriceQuote{Price: money.New(1250, "EUR"), Source: PriceSourceProduct}, *quotes[2])
+	assert.Equal(t, money.New(1839, "EUR"), quotes[3].Price)
+	assert.Equal(t, PriceSourceExchangeRate, quotes[3].Source)
+	assert.Equal(t, "0.92", quotes[3].ExchangeRate.Rate)
+	assert.Equal(t, money.New(460, "EUR"), quotes[4].Price)
+}
+
+func TestQuoteInvertsRatesStoredForTheOppositeDirection(t *testing.T) {
+	service, mock := newPricingService(t)
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT .* FROM product_prices").WillReturnRows(sqlmock.NewRows(productPriceColumnNames))
+	mock.ExpectQuery("SELECT .* FROM exchange_rates").
+		WillReturnRows(sqlmock.NewRows(exchangeRateColumnNames).AddRow("EUR", "USD", "1.25", now))
+
+	quotes, err := service.Quote(context.Background(), []models.Product{pricedProduct("p1", money.New(1000, "USD"))}, "EUR", "")
+	assert.NoError(t, err)
+	assert.Equal(t, money.New(800, "EUR"), quotes[0].Price)
+	assert.Equal(t, models.ExchangeRate{Base: "USD", Quote: "EUR", Rate: "0.8", EffectiveAt: now}, *quotes[0].ExchangeRate)
+}
+
+func TestQuoteLeavesProductsWithoutPriceOrRateUnpriced(t *testing.T) {
+	service, mock := newPricingService(t)
+	products := []models.Product{
+		pricedProduct("p1", money.New(1000, "USD")),
+		pricedProduct("p2", money.New(700, "JPY")),
+		pricedProduct("p3", money.New(2000, "USD")),
+	}
+
+	mock.ExpectQuery("SELECT .* FROM product_prices").WillReturnRows(sqlmock.NewRows(productPriceColumnNames))
+	// The missing rate is looked up once
+	mock.ExpectQuery("SELECT .* FROM exchange_rates").WillReturnError(sql.ErrNoRows)
+
+	quotes, err := service.Quote(context.Background(), products, "JPY", "")
+	assert.NoError(t, err)
+	assert.Nil(t, quotes[0])
+	assert.Equal(t, money.New(700, "JPY"), quotes[1].Price)
+	assert.Nil(t, quotes[2])
+}
+
+func TestQuoteProductNamesTheMissingCurrencyPair(t *testing.T) {
+	service, mock := newPricingService(t)
+
+	mock.ExpectQuery("SELECT .* FROM product_prices").WillReturnRows(sqlmock.NewRows(productPriceColumnNames))
+	mock.ExpectQuery("SELECT .* FROM exchange_rates").WillReturnError(sql.ErrNoRows)
+
+	_, err := service.QuoteProduct(context.Background(), pricedProduct("p1", money.New(1000, "USD")), "JPY", "")
+	assert.ErrorIs(t, err, ErrCurrencyUnavailable)
+	appErr, ok := apperr.As(err)
+	assert.True(t, ok)
+	assert.Equal(t, "currency_unavailable", appErr.Code)
+	assert.Contains(t, appErr.Message, "from USD to JPY")
+}
+
+func TestQuoteLeavesOverflowingConversionsUnpriced(t *testing.T) {
+	service, mock := newPricingService(t)
+	now := time.Now()
+	products := []models.Product{
+		pricedProduct("p1", money.New(math.MaxInt64/100, "USD")),
+		pricedProduct("p2", money.New(1000, "USD")),
+	}
+
+	mock.ExpectQuery("SELECT .* FROM product_prices").WillReturnRows(sqlmock.NewRows(productPriceColumnNames))
+	mock.ExpectQuery("SELECT .* FROM exchange_rates").
+		WillReturnRows(sqlmock.NewRows(exchangeRateColumnNames).AddRow("USD", "KRW", "9999999999", now))
+
+	quotes, err := service.Quote(context.Background(), products, "KRW", "")
+	assert.NoError(t, err)
+	assert.Nil(t, quotes[0])
+	assert.Equal(t, money.New(99999999990, "KRW"), quotes[1].Price)
+
+	mock.ExpectQuery("SELECT .* FROM product_prices").WillReturnRows(sqlmock.NewRows(productPriceColumnNames))
+	mock.ExpectQuery("SELECT .* FROM exchange_rates").
+		WillReturnRows(sqlmock.NewRows(exchangeRateColumnNames).AddRow("USD", "KRW", "9999999999", now))
+
+	_, err = service.QuoteProduct(context.Background(), products[0], "KRW", "")
+	var errs validation.Errors
+	assert.ErrorAs(t, err, &errs)
+}
+
+func TestSetExchangeRateValidates(t *testing.T) {
+	service, _ := newPricingService(t)
+
+	err := service.SetExchangeRate(context.Background(), &models.ExchangeRate{Base: "USD", Quote: "USD", Rate: "0"})
+	var fields validation.Errors
+	assert.ErrorAs(t, err, &fields)
+	assert.Equal(t, "quote", fields[0].Field)
+	assert.Equal(t, "rate", fields[1].Field)
+}
@@ -2,56 +2,416 @@ package services
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
 	"log"
+	"products-api/internal/apperr"
+	"products-api/internal/events"
 	"products-api/internal/models"
+	"products-api/internal/money"
 	"products-api/internal/repository"
+	"products-api/internal/validation"
+	"slices"
+	"sort"
+	"time"
 )
 
-// ProductService handles product business logic
+// MaxImportBatch bounds how many products one ImportProducts call stores.
+const MaxImportBatch = 1000
+
+var (
+	// ErrInvalidQuantity is returned when a stock change is not a positive number of units.
+	ErrInvalidQuantity = apperr.New(apperr.BadRequest, "invalid_quantity", "quantity must be greater than zero")
+	// ErrQuantityBelowReserved is returned when an update would leave fewer
+	// units on hand than pending reservations hold.
+	ErrQuantityBelowReserved = apperr.New(apperr.Conflict, "quantity_below_reserved", "quantity must not be less than the units held by pending reservations")
+	// ErrPreconditionFailed is returned when a conditional write finds the
+	// product at a version other than the one the client read.
+	ErrPreconditionFailed = apperr.New(apperr.PreconditionFailed, "precondition_failed", "the product has been modified since it was read")
+)
+
+// Precondition is the product version a client expects a write to find,
+// taken from an If-Match header.
+type Precondition struct {
+	// Any matches every version of an existing product, as If-Match: * does
+	Any      bool
+	Versions []int
+}
+
+// Check returns ErrPreconditionFailed unless product is at an expected version
+func (p Precondition) Check(product *models.Product) error {
+	if p.Any || slices.Contains(p.Versions, product.Version) {
+		return nil
+	}
+	return ErrPreconditionFailed
+}
+
+// ProductService handles product business logic. Every change is written in
+// the same transaction as the domain events describing it, which the outbox
+// relay publishes afterwards.
 type ProductService struct {
-	repo *repository.ProductRepository
+	repo         *repository.ProductRepository
+	reservations *repository.ReservationRepository
+	inbox        *repository.InboxRepository
+	outbox       *repository.OutboxRepository
+	tx           *repository.Transactor
 }
 
+// Create stores a new product under a server-assigned ID, discarding any ID set by the caller
 func (s *ProductService) Create(context context.Context, product *models.Product) error {
-	err := s.repo.Create(context, product)
+	product.SetID()
+	err := s.create(context, product)
 	if err != nil {
 		log.Printf("Error creating product: %v", err)
 	}
 	return err
 }
 
-// GetProducts retrieves all products
-func (s *ProductService) GetProducts(ctx context.Context) ([]models.Product, error) {
-	products, err := s.repo.GetAll(ctx)
+// Import stores a product under the caller-supplied ID, generating one only when it is empty.
+// It is meant for migrating catalogs from other systems where IDs must be preserved.
+func (s *ProductService) Import(ctx context.Context, product *models.Product) error {
+	if product.ID == "" {
+		product.SetID()
+	}
+	err := s.create(ctx, product)
+	if err != nil {
+		log.Printf("Error importing product %s: %v", product.ID, err)
+	}
+	return err
+}
+
+func (s *ProductService) create(ctx context.Context, product *models.Product) error {
+	if err := product.Validate(); err != nil {
+		return err
+	}
+	return s.tx.WithinTx(ctx, func(tx *sql.Tx) error {
+		if err := s.repo.WithTx(tx).Create(ctx, product); err != nil {
+			return err
+		}
+		return recordEvent(ctx, s.outbox.WithTx(tx), events.ProductCreatedType, product.ID, events.ProductChanged{Product: *product})
+	})
+}
+
+// ImportProducts stores a batch of products in one transaction, keeping
+// caller-supplied IDs and generating the missing ones. Every product is
+// validated first, and validation.Errors lists the invalid fields of all of
+// them as products[i].field, so either the whole batch is stored or none of it.
+func (s *ProductService) ImportProducts(ctx context.Context, products []models.Product) error {
+	checks := []validation.Check{
+		validation.Field("products", products, validation.NotEmpty[models.Product], validation.MaxItems[models.Product](MaxImportBatch)),
+	}
+	if len(products) <= MaxImportBatch {
+		for i := range products {
+			checks = append(checks, validation.Nested(fmt.Sprintf("products[%d]", i), products[i].Validate()))
+		}
+	}
+	if err := validation.Validate(checks...); err != nil {
+		return err
+	}
+
+	for i := range products {
+		if products[i].ID == "" {
+			products[i].SetID()
+		}
+	}
+	err := s.tx.WithinTx(ctx, func(tx *sql.Tx) error {
+		repo, outbox := s.repo.WithTx(tx), s.outbox.WithTx(tx)
+		for i := range products {
+			if err := repo.Create(ctx, &products[i]); err != nil {
+				return fmt.Errorf("product %s: %w", products[i].ID, err)
+			}
+			if err := recordEvent(ctx, outbox, events.ProductCreatedType, products[i].ID, events.ProductChanged{Product: products[i]}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error importing %d products: %v", len(products), err)
+		return err
+	}
+	log.Printf("Imported %d products", len(products))
+	return nil
+}
+
+// GetProducts retrieves one page of products matching the query
+func (s *ProductService) GetProducts(ctx context.Context, query repository.ProductQuery) (*repository.ProductPage, error) {
+	page, err := s.repo.GetAll(ctx, query)
 	if err != nil {
 		log.Printf("Error retrieving products: %v", err)
 		return nil, err
 	}
-	log.Printf("Retrieved %d products %v", len(products), products)
-	return products, nil
+	log.Printf("Retrieved %d products", len(page.Items))
+	return page, nil
 }
 
-func (s *ProductService) UpdateProductCount(ctx context.Context, id string, sold int) (*models.Product, error) {
-	product, err := s.repo.GetProductByID(ctx, id)
+// GetProductByID retrieves a single product, including a soft-deleted one when includeDeleted is set
+func (s *ProductService) GetProductByID(ctx context.Context, id string, includeDeleted bool) (*models.Product, error) {
+	product, err := s.repo.FindProductByID(ctx, id, includeDeleted)
 	if err != nil {
-		log.Printf("Error retrieving product by ID: %v", err)
+		log.Printf("Error retrieving product %s: %v", id, err)
 		return nil, err
 	}
+	return product, nil
+}
+
+// UpdateProduct replaces the mutable fields of the product with the given ID
+// if it is at a version matching precondition
+func (s *ProductService) UpdateProduct(ctx context.Context, id string, precondition Precondition, product *models.Product) error {
+	product.ID = id
+	if err := product.Validate(); err != nil {
+		return err
+	}
+	updated, err := s.update(ctx, id, precondition, func(current *models.Product) (*models.Product, error) {
+		return product, nil
+	})
+	if err != nil {
+		log.Printf("Error updating product %s: %v", id, err)
+		return err
+	}
+	*product = *updated
+	return nil
+}
+
+// PatchProduct applies a JSON merge patch to the product with the given ID if
+// it is at a version matching precondition. Server-managed fields (id,
+// timestamps and version) cannot be changed through a patch. The patched
+// product is validated like a replacement and returns validation.Errors.
+func (s *ProductService) PatchProduct(ctx context.Context, id string, precondition Precondition, patch []byte) (*models.Product, error) {
+	product, err := s.update(ctx, id, precondition, func(current *models.Product) (*models.Product, error) {
+		doc, err := json.Marshal(current)
+		if err != nil {
+			return nil, err
+		}
+		patched, err := applyMergePatch(doc, patch)
+		if err != nil {
+			return nil, err
+		}
+
+		var product models.Product
+		if err := json.Unmarshal(patched, &product); err != nil {
+			if money.IsInvalid(err) {
+				return nil, validation.Errors{{Field: "price", Message: err.Error()}}
+			}
+			return nil, ErrInvalidPatch
+		}
+		product.BaseModel = current.BaseModel
+		if err := product.Validate(); err != nil {
+			return nil, err
+		}
+		return &product, nil
+	})
+	if err != nil {
+		log.Printf("Error patching product %s: %v", id, err)
+		return nil, err
+	}
+	return product, nil
+}
+
+// update locks the product, checks precondition against its version, lets
+// change compute its new state from the current one, stores it and records
+// ProductUpdated plus any stock change. It returns ErrQuantityBelowReserved
+// rather than let the quantity drop below the reserved units.
+func (s *ProductService) update(ctx context.Context, id string, precondition Precondition, change func(current *models.Product) (*models.Product, error)) (*models.Product, error) {
+	var product *models.Product
+	err := s.tx.WithinTx(ctx, func(tx *sql.Tx) error {
+		products := s.repo.WithTx(tx)
+		current, err := products.GetProductByIDForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := precondition.Check(current); err != nil {
+			return err
+		}
+		product, err = change(current)
+		if err != nil {
+			return err
+		}
+		// The row lock keeps reservations from growing before the update
+		if product.Quantity < current.ReservedQuantity {
+			return ErrQuantityBelowReserved
+		}
+		if err := products.Update(ctx, product); err != nil {
+			return err
+		}
+
+		outbox := s.outbox.WithTx(tx)
+		if err := recordEvent(ctx, outbox, events.ProductUpdatedType, product.ID, events.ProductChanged{Product: *product}); err != nil {
+			return err
+		}
+		return recordStockChange(ctx, outbox, product, product.Quantity-current.Quantity, current.AvailableQuantity, events.StockReasonUpdate)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// DeleteProduct soft deletes the product with the given ID if it is at a
+// version matching precondition. Its pending reservations are released, so
+// they can no longer be confirmed.
+func (s *ProductService) DeleteProduct(ctx context.Context, id string, precondition Precondition) error {
+	err := s.tx.WithinTx(ctx, func(tx *sql.Tx) error {
+		products := s.repo.WithTx(tx)
+		current, err := products.GetProductByIDForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := precondition.Check(current); err != nil {
+			return err
+		}
+		if _, err := s.reservations.WithTx(tx).ReleaseByProduct(ctx, id); err != nil {
+			return err
+		}
+		if err := products.DeleteProduct(ctx, id); err != nil {
+			return err
+		}
+		return recordEvent(ctx, s.outbox.WithTx(tx), events.ProductDeletedType, id, events.ProductDeleted{ProductID: id})
+	})
+	if err != nil {
+		log.Printf("Error deleting product %s: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+// RestoreProduct undoes a soft delete
+func (s *ProductService) RestoreProduct(ctx context.Context, id string) (*models.Product, error) {
+	var product *models.Product
+	err := s.tx.WithinTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		product, err = s.repo.WithTx(tx).RestoreProduct(ctx, id)
+		if err != nil {
+			return err
+		}
+		return recordEvent(ctx, s.outbox.WithTx(tx), events.ProductUpdatedType, id, events.ProductChanged{Product: *product})
+	})
+	if err != nil {
+		log.Printf("Error restoring product %s: %v", id, err)
+		return nil, err
+	}
+	return product, nil
+}
+
+// PurgeDeletedProducts permanently removes products that have been soft deleted for longer than retention
+func (s *ProductService) PurgeDeletedProducts(ctx context.Context, retention time.Duration) (int64, error) {
+	purged, err := s.repo.PurgeDeleted(ctx, time.Now().Add(-retention))
+	if err != nil {
+		log.Printf("Error purging deleted products: %v", err)
+		return 0, err
+	}
+	if purged > 0 {
+		log.Printf("Purged %d products deleted more than %s ago", purged, retention)
+	}
+	return purged, nil
+}
+
+// UpdateProductCount decrements a product's stock by sold units. It returns
+// repository.ErrInsufficientStock instead of letting quantity go negative.
+func (s *ProductService) UpdateProductCount(ctx context.Context, id string, sold int) (*models.Product, error) {
+	if sold <= 0 {
+		return nil, ErrInvalidQuantity
+	}
 	log.Printf("Updating product count for product ID %s, sold: %d", id, sold)
-	err = s.repo.UpdateProductCount(ctx, product, sold)
+	var product *models.Product
+	err := s.tx.WithinTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		product, err = s.repo.WithTx(tx).UpdateProductCount(ctx, id, sold)
+		if err != nil {
+			return err
+		}
+		return recordStockChange(ctx, s.outbox.WithTx(tx), product, -sold, product.AvailableQuantity+sold, events.StockReasonSale)
+	})
 	if err != nil {
 		log.Printf("Error updating product count: %v", err)
 		return nil, err
 	}
-	log.Printf("Updated product count successfully for product  %v", product)
+	log.Printf("Updated product count successfully for product %s, remaining: %d", id, product.Quantity)
 	return product, nil
 }
 
+// ApplyOrder decrements stock for every line item of an order in a single
+// transaction, so either all items are taken from stock or none are. Items for
+// the same product are combined and applied in product ID order, so concurrent
+// orders lock rows in the same order and cannot deadlock.
+//
+// The message ID and order ID are written to the processed-messages ledger in
+// the same transaction, so a redelivered message or a republished order is
+// acknowledged without touching stock again.
+func (s *ProductService) ApplyOrder(ctx context.Context, messageID string, order events.OrderCreated) error {
+	if err := order.Validate(); err != nil {
+		return err
+	}
+	if messageID == "" {
+		messageID = order.OrderID
+	}
+
+	quantities := make(map[string]int, len(order.Items))
+	for _, item := range order.Items {
+		quantities[item.ProductID] += item.Quantity
+	}
+	productIDs := make([]string, 0, len(quantities))
+	for id := range quantities {
+		productIDs = append(productIDs, id)
+	}
+	sort.Strings(productIDs)
+
+	duplicate := false
+	err := s.tx.WithinTx(ctx, func(tx *sql.Tx) error {
+		first, err := s.inbox.WithTx(tx).MarkProcessed(ctx, messageID, order.OrderID)
+		if err != nil {
+			return err
+		}
+		if !first {
+			duplicate = true
+			return nil
+		}
+
+		products, outbox := s.repo.WithTx(tx), s.outbox.WithTx(tx)
+		for _, id := range productIDs {
+			product, err := products.UpdateProductCount(ctx, id, quantities[id])
+			if err != nil {
+				return fmt.Errorf("product %s: %w", id, err)
+			}
+			if err := recordStockChange(ctx, outbox, product, -quantities[id], product.AvailableQuantity+quantities[id], events.StockReasonOrder); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error applying order %s: %v", order.OrderID, err)
+		return err
+	}
+	if duplicate {
+		log.Printf("Skipping order %s from message %s: already processed", order.OrderID, messageID)
+		return nil
+	}
+	log.Printf("Applied order %s to stock for %d products", order.OrderID, len(productIDs))
+	return nil
+}
+
+// PurgeProcessedMessages removes processed-message ledger entries older than retention.
+// Retention must exceed the queue's message retention period, or a late
+// redelivery could be applied a second time.
+func (s *ProductService) PurgeProcessedMessages(ctx context.Context, retention time.Duration) (int64, error) {
+	purged, err := s.inbox.DeleteProcessedBefore(ctx, time.Now().Add(-retention))
+	if err != nil {
+		log.Printf("Error purging processed messages: %v", err)
+		return 0, err
+	}
+	if purged > 0 {
+		log.Printf("Purged %d processed messages older than %s", purged, retention)
+	}
+	return purged, nil
+}
+
 // CreateProduct creates a new product
 // func (s *ProductService) CreateProduct(ctx context.Context, req models.ProductCreateRequest) (*models.Product, error) {
 // 	return s.repo.CreateProduct(ctx, req)
 // }
 
-func NewProductService(repo *repository.ProductRepository) *ProductService {
-	return &ProductService{repo: repo}
+func NewProductService(repo *repository.ProductRepository, reservations *repository.ReservationRepository, inbox *repository.InboxRepository, outbox *repository.OutboxRepository, tx *repository.Transactor) *ProductService {
+	return &ProductService{repo: repo, reservations: reservations, inbox: inbox, outbox: outbox, tx: tx}
 }
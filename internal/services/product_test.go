@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"products-api/internal/events"
+	"products-api/internal/models"
+	"products-api/internal/money"
+	"products-api/internal/repository"
+	"products-api/internal/validation"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func newProductService(t *testing.T) (*ProductService, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+	t.Cleanup(func() {
+		assert.NoError(t, mock.ExpectationsWereMet())
+		db.Close()
+	})
+	return NewProductService(repository.NewProductRepository(db), repository.NewReservationRepository(db), repository.NewInboxRepository(db), repository.NewOutboxRepository(db), repository.NewTransactor(db)), mock
+}
+
+// expectOutboxEvent expects one event for aggregateID to be written to the outbox.
+func expectOutboxEvent(mock sqlmock.Sqlmock, aggregateID, eventType string) {
+	mock.ExpectQuery("INSERT INTO outbox").WithArgs(sqlmock.AnyArg(), aggregateID, eventType, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+}
+
+func TestCreateRecordsProductCreated(t *testing.T) {
+	service, mock := newProductService(t)
+	now := time.Now()
+	product := models.Product{Name: "New Product", Price: money.New(500, "USD"), Quantity: 1}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO products").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "seller_id", "quantity", "created_at", "updated_at", "version"}).AddRow("p1", "New Product", "", 1, now, now, 1))
+	expectOutboxEvent(mock, "p1", events.ProductCreatedType)
+	mock.ExpectCommit()
+
+	assert.NoError(t, service.Create(context.Background(), &product))
+}
+
+func TestUpdateProductRecordsStockChange(t *testing.T) {
+	service, mock := newProductService(t)
+	now := time.Now()
+	product := models.Product{Name: "Product", Price: money.New(999, "USD"), Quantity: 0}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .* FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs("p1").
+		WillReturnRows(sqlmock.NewRows(productColumnNames).AddRow("p1", "Product", 9.99, "", 3, now, now, nil, 0, "USD", 1))
+	mock.ExpectQuery("UPDATE products SET name").
+		WillReturnRows(sqlmock.NewRows(productColumnNames).AddRow("p1", "Product", 9.99, "", 0, now, now, nil, 0, "USD", 2))
+	expectOutboxEvent(mock, "p1", events.ProductUpdatedType)
+	expectOutboxEvent(mock, "p1", events.StockChangedType)
+	expectOutboxEvent(mock, "p1", events.OutOfStockType)
+	mock.ExpectCommit()
+
+	assert.NoError(t, service.UpdateProduct(context.Background(), "p1", Precondition{Versions: []int{1}}, &product))
+	assert.Equal(t, "p1", product.ID)
+	assert.Equal(t, 2, product.Version)
+}
+
+func TestUpdateProductRejectsStaleVersion(t *testing.T) {
+	service, mock := newProductService(t)
+	now := time.Now()
+	product := models.Product{Name: "Product", Price: money.New(999, "USD")}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .* FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs("p1").
+		WillReturnRows(sqlmock.NewRows(productColumnNames).AddRow("p1", "Product", 9.99, "", 3, now, now, nil, 0, "USD", 4))
+	mock.ExpectRollback()
+
+	err := service.UpdateProduct(context.Background(), "p1", Precondition{Versions: []int{2, 3}}, &product)
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+}
+
+func TestUpdateProductKeepsReservedUnits(t *testing.T) {
+	service, mock := newProductService(t)
+	now := time.Now()
+	product := models.Product{Name: "Product", Price: money.New(999, "USD"), Quantity: 1}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .* FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs("p1").
+		WillReturnRows(sqlmock.NewRows(productColumnNames).AddRow("p1", "Product", 9.99, "", 5, now, now, nil, 2, "USD", 1))
+	mock.ExpectRollback()
+
+	err := service.UpdateProduct(context.Background(), "p1", Precondition{Any: true}, &product)
+	assert.ErrorIs(t, err, ErrQuantityBelowReserved)
+}
+
+func TestDeleteProductRollsBackWhenMissing(t *testing.T) {
+	service, mock := newProductService(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .* FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs("404").WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	assert.ErrorIs(t, service.DeleteProduct(context.Background(), "404", Precondition{Any: true}), sql.ErrNoRows)
+}
+
+func TestDeleteProductChecksVersionAndReleasesReservations(t *testing.T) {
+	service, mock := newProductService(t)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .* FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs("p1").
+		WillReturnRows(sqlmock.NewRows(productColumnNames).AddRow("p1", "Product", 9.99, "", 3, now, now, nil, 0, "USD", 2))
+	mock.ExpectExec("UPDATE reservations SET status = \\$1.* WHERE product_id = \\$2 AND status = \\$3").
+		WithArgs(models.ReservationReleased, "p1", models.ReservationPending).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE products SET deleted_at = NOW\\(\\), reserved_quantity = 0, version = version \\+ 1").WithArgs("p1").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectOutboxEvent(mock, "p1", events.ProductDeletedType)
+	mock.ExpectCommit()
+
+	assert.NoError(t, service.DeleteProduct(context.Background(), "p1", Precondition{Versions: []int{2}}))
+}
+
+func TestApplyOrderDecrementsEveryItemInOneTransaction(t *testing.T) {
+	service, mock := newProductService(t)
+	now := time.Now()
+	order := events.OrderCreated{
+		OrderID: "o1",
+		Items: []events.OrderLineItem{
+			{ProductID: "p2", Quantity: 1},
+			{ProductID: "p1", Quantity: 2},
+			{ProductID: "p2", Quantity: 3},
+		},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO processed_messages").WithArgs("m1", "o1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE products SET quantity = quantity - \\$1").WithArgs(2, "p1").
+		WillReturnRows(sqlmock.NewRows(productColumnNames).AddRow("p1", "Product 1", 9.99, "", 8, now, now, nil, 0, "USD", 1))
+	expectOutboxEvent(mock, "p1", events.StockChangedType)
+	mock.ExpectQuery("UPDATE products SET quantity = quantity - \\$1").WithArgs(4, "p2").
+		WillReturnRows(sqlmock.NewRows(productColumnNames).AddRow("p2", "Product 2", 9.99, "", 0, now, now, nil, 0, "USD", 1))
+	expectOutboxEvent(mock, "p2", events.StockChangedType)
+	expectOutboxEvent(mock, "p2", events.OutOfStockType)
+	mock.ExpectCommit()
+
+	assert.NoError(t, service.ApplyOrder(context.Background(), "m1", order))
+}
+
+func TestApplyOrderSkipsAlreadyProcessedMessages(t *testing.T) {
+	service, mock := newProductService(t)
+	order := events.OrderCreated{OrderID: "o1", Items: []events.OrderLineItem{{ProductID: "p1", Quantity: 2}}}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO processed_messages").WithArgs("m1", "o1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	assert.NoError(t, service.ApplyOrder(context.Background(), "m1", order), "expected a redelivery to be acknowledged")
+}
+
+func TestApplyOrderRollsBackWhenAnyItemFails(t *testing.T) {
+	service, mock := newProductService(t)
+	now := time.Now()
+	order := events.OrderCreated{
+		OrderID: "o1",
+		Items: []events.OrderLineItem{
+			{ProductID: "p1", Quantity: 1},
+			{ProductID: "p2", Quantity: 100},
+		},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO processed_messages").WithArgs("m1", "o1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE products SET quantity = quantity - \\$1").WithArgs(1, "p1").
+		WillReturnRows(sqlmock.NewRows(productColumnNames).AddRow("p1", "Product 1", 9.99, "", 9, now, now, nil, 0, "USD", 1))
+	expectOutboxEvent(mock, "p1", events.StockChangedType)
+	mock.ExpectQuery("UPDATE products SET quantity = quantity - \\$1").WithArgs(100, "p2").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT EXISTS").WithArgs("p2").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	err := service.ApplyOrder(context.Background(), "m1", order)
+	assert.ErrorIs(t, err, repository.ErrInsufficientStock)
+}
+
+func TestApplyOrderRejectsInvalidOrders(t *testing.T) {
+	service, _ := newProductService(t)
+
+	err := service.ApplyOrder(context.Background(), "m1", events.OrderCreated{OrderID: "o1"})
+	assert.ErrorIs(t, err, events.ErrInvalidEvent)
+}
+
+func TestImportProductsStoresBatchInOneTransaction(t *testing.T) {
+	service, mock := newProductService(t)
+	now := time.Now()
+	products := []models.Product{{BaseModel: models.BaseModel{ID: "legacy-1"}, Name: "One", Price: money.New(100, "USD")}, {Name: "Two", Price: money.New(200, "USD")}}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO products").WithArgs("legacy-1", "One", "1.00", money.Currency("USD"), "", 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "seller_id", "quantity", "created_at", "updated_at", "version"}).AddRow("legacy-1", "One", "", 0, now, now, 1))
+	expectOutboxEvent(mock, "legacy-1", events.ProductCreatedType)
+	mock.ExpectQuery("INSERT INTO products").WithArgs(sqlmock.AnyArg(), "Two", "2.00", money.Currency("USD"), "", 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "seller_id", "quantity", "created_at", "updated_at", "version"}).AddRow("p2", "Two", "", 0, now, now, 1))
+	expectOutboxEvent(mock, "p2", events.ProductCreatedType)
+	mock.ExpectCommit()
+
+	assert.NoError(t, service.ImportProducts(context.Background(), products))
+	assert.Equal(t, "legacy-1", products[0].ID)
+	assert.Equal(t, "p2", products[1].ID)
+}
+
+func TestImportProductsRejectsInvalidBatch(t *testing.T) {
+	service, _ := newProductService(t)
+	products := []models.Product{{Name: "Valid", Price: money.New(100, "USD")}, {Name: "", Price: money.New(-100, "USD")}}
+
+	err := service.ImportProducts(context.Background(), products)
+	var fields validation.Errors
+	assert.ErrorAs(t, err, &fields)
+	assert.Equal(t, "products[1].name", fields[0].Field)
+	assert.Equal(t, "products[1].price", fields[1].Field)
+}
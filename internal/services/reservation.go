@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"products-api/internal/apperr"
+	"products-api/internal/events"
+	"products-api/internal/models"
+	"products-api/internal/repository"
+	"products-api/internal/validation"
+	"time"
+)
+
+// sweepBatchSize bounds how many expired reservations one sweeper transaction releases.
+const sweepBatchSize = 100
+
+var (
+	// ErrInvalidCart is returned when a reservation is requested without a cart ID.
+	ErrInvalidCart = apperr.New(apperr.BadRequest, "invalid_cart", "cart_id is required")
+	// ErrReservationNotPending is returned when confirming or releasing a
+	// reservation that was already confirmed, released or expired.
+	ErrReservationNotPending = apperr.New(apperr.Conflict, "reservation_not_pending", "reservation is no longer pending")
+	// ErrReservationExpired is returned when confirming a reservation after its TTL.
+	ErrReservationExpired = apperr.New(apperr.Conflict, "reservation_expired", "reservation has expired")
+)
+
+// ReservationService holds stock for checkouts until they are paid for or abandoned
+type ReservationService struct {
+	tx           *repository.Transactor
+	products     *repository.ProductRepository
+	reservations *repository.ReservationRepository
+	outbox       *repository.OutboxRepository
+	defaultTTL   time.Duration
+	maxTTL       time.Duration
+}
+
+func NewReservationService(tx *repository.Transactor, products *repository.ProductRepository, reservations *repository.ReservationRepository, outbox *repository.OutboxRepository, defaultTTL, maxTTL time.Duration) *ReservationService {
+	return &ReservationService{
+		tx:           tx,
+		products:     products,
+		reservations: reservations,
+		outbox:       outbox,
+		defaultTTL:   defaultTTL,
+		maxTTL:       maxTTL,
+	}
+}
+
+// MaxTTL returns the longest time a reservation may hold stock
+func (s *ReservationService) MaxTTL() time.Duration {
+	return s.maxTTL
+}
+
+// Reserve holds quantity units of a product for a cart. A ttl of zero uses
+// the service default; a ttl above MaxTTL is a validation error.
+func (s *ReservationService) Reserve(ctx context.Context, productID, cartID string, quantity int, ttl time.Duration) (*models.Reservation, error) {
+	if quantity <= 0 {
+		return nil, ErrInvalidQuantity
+	}
+	if cartID == "" {
+		return nil, ErrInvalidCart
+	}
+	if err := validation.Validate(validation.Field("ttl_seconds", ttl, validation.Max(s.maxTTL))); err != nil {
+		return nil, err
+	}
+	if ttl <= 0 {
+		ttl = s.defaultTTL
+	}
+
+	reservation := &models.Reservation{
+		ProductID: productID,
+		CartID:    cartID,
+		Quantity:  quantity,
+		Status:    models.ReservationPending,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	reservation.SetID()
+
+	err := s.tx.WithinTx(ctx, func(tx *sql.Tx) error {
+		product, err := s.products.WithTx(tx).Reserve(ctx, productID, quantity)
+		if err != nil {
+			return err
+		}
+		if err := s.reservations.WithTx(tx).Create(ctx, reservation); err != nil {
+			return err
+		}
+		// Reserving leaves quantity unchanged but can use up the last available units
+		return recordStockChange(ctx, s.outbox.WithTx(tx), product, 0, product.AvailableQuantity+quantity, "")
+	})
+	if err != nil {
+		log.Printf("Error reserving %d units of product %s for cart %s: %v", quantity, productID, cartID, err)
+		return nil, err
+	}
+	log.Printf("Reserved %d units of product %s for cart %s until %s", quantity, productID, cartID, reservation.ExpiresAt.Format(time.RFC3339))
+	return reservation, nil
+}
+
+// GetReservation retrieves a reservation by ID
+func (s *ReservationService) GetReservation(ctx context.Context, id string) (*models.Reservation, error) {
+	return s.reservations.GetByID(ctx, id)
+}
+
+// Confirm converts a pending reservation into a sale, removing the reserved
+// units from stock. Confirming after the TTL releases the units instead and
+// returns ErrReservationExpired.
+func (s *ReservationService) Confirm(ctx context.Context, id string) (*models.Reservation, error) {
+	var (
+		reservation *models.Reservation
+		expired     bool
+	)
+	err := s.tx.WithinTx(ctx, func(tx *sql.Tx) error {
+		reservations := s.reservations.WithTx(tx)
+		var err error
+		reservation, err = s.lock(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if reservation.Status != models.ReservationPending {
+			return ErrReservationNotPending
+		}
+		if time.Now().After(reservation.ExpiresAt) {
+			expired = true
+			return s.finish(ctx, tx, reservation, models.ReservationExpired)
+		}
+		product, err := s.products.WithTx(tx).CommitReserved(ctx, reservation.ProductID, reservation.Quantity)
+		if err != nil {
+			return err
+		}
+		if err := reservations.UpdateStatus(ctx, reservation, models.ReservationConfirmed); err != nil {
+			return err
+		}
+		return recordStockChange(ctx, s.outbox.WithTx(tx), product, -reservation.Quantity, product.AvailableQuantity, events.StockReasonReservation)
+	})
+	if err != nil {
+		log.Printf("Error confirming reservation %s: %v", id, err)
+		return nil, err
+	}
+	if expired {
+		return nil, ErrReservationExpired
+	}
+	return reservation, nil
+}
+
+// Release cancels a pending reservation and returns its units to available stock.
+func (s *ReservationService) Release(ctx context.Context, id string) (*models.Reservation, error) {
+	var reservation *models.Reservation
+	err := s.tx.WithinTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		reservation, err = s.lock(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if reservation.Status != models.ReservationPending {
+			return ErrReservationNotPending
+		}
+		return s.finish(ctx, tx, reservation, models.ReservationReleased)
+	})
+	if err != nil {
+		log.Printf("Error releasing reservation %s: %v", id, err)
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// ExpireReservations releases every pending reservation past its expiry and
+// returns how many were released. It is run periodically by a background job.
+func (s *ReservationService) ExpireReservations(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		listed, released := 0, 0
+		err := s.tx.WithinTx(ctx, func(tx *sql.Tx) error {
+			reservations := s.reservations.WithTx(tx)
+			expired, err := reservations.ListExpired(ctx, time.Now(), sweepBatchSize)
+			if err != nil {
+				return err
+			}
+			listed = len(expired)
+			productIDs := make([]string, len(expired))
+			for i, reservation := range expired {
+				productIDs[i] = reservation.ProductID
+			}
+			if err := s.products.WithTx(tx).LockProducts(ctx, productIDs...); err != nil {
+				return err
+			}
+			for _, candidate := range expired {
+				// Another sweeper or a confirm may have finished it before the lock
+				reservation, err := reservations.GetByIDForUpdate(ctx, candidate.ID)
+				if err != nil {
+					return err
+				}
+				if reservation.Status != models.ReservationPending {
+					continue
+				}
+				if err := s.finish(ctx, tx, reservation, models.ReservationExpired); err != nil {
+					return err
+				}
+				released++
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Error expiring reservations: %v", err)
+			return total, err
+		}
+		total += released
+		if listed < sweepBatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		log.Printf("Expired %d reservations", total)
+	}
+	return total, nil
+}
+
+// lock locks a reservation and, before it, its product, the order in which
+// every transaction that changes both takes their locks.
+func (s *ReservationService) lock(ctx context.Context, tx *sql.Tx, id string) (*models.Reservation, error) {
+	reservations := s.reservations.WithTx(tx)
+	// A reservation never moves to another product, so it can be read unlocked
+	reservation, err := reservations.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.products.WithTx(tx).LockProducts(ctx, reservation.ProductID); err != nil {
+		return nil, err
+	}
+	return reservations.GetByIDForUpdate(ctx, id)
+}
+
+// finish returns a reservation's units to available stock and moves it to a terminal status.
+func (s *ReservationService) finish(ctx context.Context, tx *sql.Tx, reservation *models.Reservation, status models.ReservationStatus) error {
+	if err := s.products.WithTx(tx).ReleaseReserved(ctx, reservation.ProductID, reservation.Quantity); err != nil {
+		return err
+	}
+	return s.reservations.WithTx(tx).UpdateStatus(ctx, reservation, status)
+}
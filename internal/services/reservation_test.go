@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"products-api/internal/models"
+	"products-api/internal/repository"
+	"products-api/internal/validation"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	productColumnNames     = []string{"id", "name", "price", "seller_id", "quantity", "created_at", "updated_at", "deleted_at", "reserved_quantity", "currency", "version"}
+	reservationColumnNames = []string{"id", "product_id", "cart_id", "quantity", "status", "expires_at", "created_at", "updated_at"}
+)
+
+func newReservationService(t *testing.T) (*ReservationService, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+	t.Cleanup(func() {
+		assert.NoError(t, mock.ExpectationsWereMet())
+		db.Close()
+	})
+	service := NewReservationService(repository.NewTransactor(db), repository.NewProductRepository(db), repository.NewReservationRepository(db), repository.NewOutboxRepository(db), time.Minute, time.Hour)
+	return service, mock
+}
+
+// expectLockedReservation expects reservation r1 of product p1 to be locked
+// after the product, reading it as status
+func expectLockedReservation(mock sqlmock.Sqlmock, status string, expiresAt time.Time) {
+	reservation := func() *sqlmock.Rows {
+		return sqlmock.NewRows(reservationColumnNames).AddRow("r1", "p1", "cart-1", 2, status, expiresAt, expiresAt, expiresAt)
+	}
+	mock.ExpectQuery("SELECT .* FROM reservations WHERE id = \\$1$").WithArgs("r1").WillReturnRows(reservation())
+	mock.ExpectExec("SELECT id FROM products WHERE id IN \\(\\$1\\) ORDER BY id FOR UPDATE").WithArgs("p1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT .* FROM reservations WHERE id = \\$1 FOR UPDATE").WithArgs("r1").WillReturnRows(reservation())
+}
+
+func TestReserveHoldsStockAndCreatesReservation(t *testing.T) {
+	service, mock := newReservationService(t)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE products SET reserved_quantity = reserved_quantity \\+ \\$1").WithArgs(2, "p1").
+		WillReturnRows(sqlmock.NewRows(productColumnNames).AddRow("p1", "Product", 9.99, "", 10, now, now, nil, 2, "USD", 1))
+	mock.ExpectQuery("INSERT INTO reservations").WithArgs(sqlmock.AnyArg(), "p1", "cart-1", 2, models.ReservationPending, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(reservationColumnNames).AddRow("r1", "p1", "cart-1", 2, "pending", now.Add(time.Minute), now, now))
+	mock.ExpectCommit()
+
+	reservation, err := service.Reserve(context.Background(), "p1", "cart-1", 2, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ReservationPending, reservation.Status)
+	assert.Equal(t, 2, reservation.Quantity)
+}
+
+func TestReserveRollsBackWhenStockIsInsufficient(t *testing.T) {
+	service, mock := newReservationService(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE products SET reserved_quantity").WithArgs(20, "p1").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT EXISTS").WithArgs("p1").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	_, err := service.Reserve(context.Background(), "p1", "cart-1", 20, 0)
+	assert.ErrorIs(t, err, repository.ErrInsufficientStock)
+}
+
+func TestConfirmExpiredReservationReleasesStock(t *testing.T) {
+	service, mock := newReservationService(t)
+	past := time.Now().Add(-time.Minute)
+
+	mock.ExpectBegin()
+	expectLockedReservation(mock, "pending", past)
+	mock.ExpectExec("UPDATE products SET reserved_quantity = GREATEST").WithArgs(2, "p1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE reservations SET status").WithArgs(models.ReservationExpired, "r1").
+		WillReturnRows(sqlmock.NewRows(reservationColumnNames).AddRow("r1", "p1", "cart-1", 2, "expired", past, past, time.Now()))
+	mock.ExpectCommit()
+
+	_, err := service.Confirm(context.Background(), "r1")
+	assert.ErrorIs(t, err, ErrReservationExpired)
+}
+
+func TestReleaseRejectsFinishedReservation(t *testing.T) {
+	service, mock := newReservationService(t)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	expectLockedReservation(mock, "confirmed", now)
+	mock.ExpectRollback()
+
+	_, err := service.Release(context.Background(), "r1")
+	assert.ErrorIs(t, err, ErrReservationNotPending)
+}
+
+func TestConfirmFailsOnceTheProductIsDeleted(t *testing.T) {
+	service, mock := newReservationService(t)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	expectLockedReservation(mock, "pending", now.Add(time.Minute))
+	mock.ExpectQuery("UPDATE products SET quantity = quantity - \\$1, reserved_quantity .* AND deleted_at IS NULL").WithArgs(2, "p1").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT EXISTS").WithArgs("p1").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectRollback()
+
+	_, err := service.Confirm(context.Background(), "r1")
+	assert.ErrorIs(t, err, repository.ErrProductNotFound)
+}
+
+func TestReserveRejectsTTLAboveMaximum(t *testing.T) {
+	service, _ := newReservationService(t)
+
+	_, err := service.Reserve(context.Background(), "p1", "cart-1", 1, 2*time.Hour)
+	var fields validation.Errors
+	assert.ErrorAs(t, err, &fields)
+	assert.Equal(t, "ttl_seconds", fields[0].Field)
+}
+
+func TestExpireReservationsLocksProductsFirstAndSkipsFinished(t *testing.T) {
+	service, mock := newReservationService(t)
+	past := time.Now().Add(-time.Minute)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .* FROM reservations\\s+WHERE status = \\$1 AND expires_at < \\$2").
+		WillReturnRows(sqlmock.NewRows(reservationColumnNames).
+			AddRow("r1", "p1", "cart-1", 2, "pending", past, past, past).
+			AddRow("r2", "p2", "cart-2", 1, "pending", past, past, past))
+	mock.ExpectExec("SELECT id FROM products WHERE id IN \\(\\$1, \\$2\\) ORDER BY id FOR UPDATE").WithArgs("p1", "p2").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery("SELECT .* FROM reservations WHERE id = \\$1 FOR UPDATE").WithArgs("r1").
+		WillReturnRows(sqlmock.NewRows(reservationColumnNames).AddRow("r1", "p1", "cart-1", 2, "pending", past, past, past))
+	mock.ExpectExec("UPDATE products SET reserved_quantity = GREATEST").WithArgs(2, "p1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE reservations SET status").WithArgs(models.ReservationExpired, "r1").
+		WillReturnRows(sqlmock.NewRows(reservationColumnNames).AddRow("r1", "p1", "cart-1", 2, "expired", past, past, time.Now()))
+	// Confirmed by the time its product was locked
+	mock.ExpectQuery("SELECT .* FROM reservations WHERE id = \\$1 FOR UPDATE").WithArgs("r2").
+		WillReturnRows(sqlmock.NewRows(reservationColumnNames).AddRow("r2", "p2", "cart-2", 1, "confirmed", past, past, past))
+	mock.ExpectCommit()
+
+	released, err := service.ExpireReservations(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, released)
+}
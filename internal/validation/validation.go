@@ -0,0 +1,163 @@
+// Package validation checks input field by field and reports every invalid
+// field at once. Rules are plain functions, so the same rules back the HTTP
+// request DTOs, bulk imports and message consumers:
+//
+//	err := validation.Validate(
+//		validation.Field("name", p.Name, validation.Required, validation.MaxLength(255)),
+//		validation.Field("quantity", p.Quantity, validation.Min(0)),
+//	)
+package validation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError describes why one field is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors lists every invalid field. It is the error Validate returns.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Field + ": " + fieldErr.Message
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// Rule returns a message describing why value is invalid, or "" if it is valid
+type Rule[T any] func(value T) string
+
+// Check validates one field or a group of fields
+type Check func() Errors
+
+// Field checks value against rules in order, reporting only the first that fails
+func Field[T any](name string, value T, rules ...Rule[T]) Check {
+	return func() Errors {
+		for _, rule := range rules {
+			if message := rule(value); message != "" {
+				return Errors{{Field: name, Message: message}}
+			}
+		}
+		return nil
+	}
+}
+
+// Nested reports the field errors of err, such as the result of a nested
+// Validate call, under prefix. Other errors are reported on prefix itself.
+func Nested(prefix string, err error) Check {
+	return func() Errors {
+		if err == nil {
+			return nil
+		}
+		nested, ok := err.(Errors)
+		if !ok {
+			return Errors{{Field: prefix, Message: err.Error()}}
+		}
+		prefixed := make(Errors, len(nested))
+		for i, fieldErr := range nested {
+			prefixed[i] = FieldError{Field: prefix + "." + fieldErr.Field, Message: fieldErr.Message}
+		}
+		return prefixed
+	}
+}
+
+// Merge reports the field errors of err, such as the result of another
+// Validate call on the same value, as they are
+func Merge(err error) Check {
+	return func() Errors {
+		if err == nil {
+			return nil
+		}
+		if errs, ok := err.(Errors); ok {
+			return errs
+		}
+		return Errors{{Message: err.Error()}}
+	}
+}
+
+// Validate runs every check and returns Errors if any field is invalid
+func Validate(checks ...Check) error {
+	var errs Errors
+	for _, check := range checks {
+		errs = append(errs, check()...)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Number is a type the numeric rules accept
+type Number interface {
+	~int | ~int64 | ~float64
+}
+
+// Required fails for an empty or blank string
+func Required(value string) string {
+	if strings.TrimSpace(value) == "" {
+		return "is required"
+	}
+	return ""
+}
+
+// NotEmpty fails for an empty list
+func NotEmpty[T any](value []T) string {
+	if len(value) == 0 {
+		return "must not be empty"
+	}
+	return ""
+}
+
+// MaxItems fails for lists of more than n items
+func MaxItems[T any](n int) Rule[[]T] {
+	return func(value []T) string {
+		if len(value) > n {
+			return fmt.Sprintf("must contain at most %d items", n)
+		}
+		return ""
+	}
+}
+
+// MaxLength fails for strings longer than n characters
+func MaxLength(n int) Rule[string] {
+	return func(value string) string {
+		if len([]rune(value)) > n {
+			return fmt.Sprintf("must be at most %d characters", n)
+		}
+		return ""
+	}
+}
+
+// Min fails for values below min
+func Min[T Number](min T) Rule[T] {
+	return func(value T) string {
+		if value < min {
+			return fmt.Sprintf("must be at least %v", min)
+		}
+		return ""
+	}
+}
+
+// Max fails for values above max
+func Max[T Number](max T) Rule[T] {
+	return func(value T) string {
+		if value > max {
+			return fmt.Sprintf("must be at most %v", max)
+		}
+		return ""
+	}
+}
+
+// Positive fails for zero and negative values
+func Positive[T Number](value T) string {
+	if value <= 0 {
+		return "must be greater than zero"
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCollectsEveryField(t *testing.T) {
+	err := Validate(
+		Field("name", "", Required, MaxLength(3)),
+		Field("code", "toolong", Required, MaxLength(3)),
+		Field("quantity", -1, Min(0)),
+		Field("price", 1.5, Min(0.0)),
+	)
+
+	var errs Errors
+	assert.True(t, errors.As(err, &errs))
+	assert.Equal(t, Errors{
+		{Field: "name", Message: "is required"},
+		{Field: "code", Message: "must be at most 3 characters"},
+		{Field: "quantity", Message: "must be at least 0"},
+	}, errs)
+}
+
+func TestValidateReturnsNilWhenValid(t *testing.T) {
+	assert.NoError(t, Validate(Field("name", "ok", Required), Field("n", 1, Positive[int])))
+}
+
+func TestNestedPrefixesFields(t *testing.T) {
+	inner := Validate(Field("price", -1.0, Min(0.0)))
+	err := Validate(Nested("products[2]", inner), Nested("file", errors.New("unreadable")))
+
+	assert.Equal(t, Errors{
+		{Field: "products[2].price", Message: "must be at least 0"},
+		{Field: "file", Message: "unreadable"},
+	}, err)
+}
+
+func TestMergeKeepsFields(t *testing.T) {
+	inner := Validate(Field("name", "", Required))
+	err := Validate(Merge(inner), Merge(nil), Field("quantity", -1, Min(0)))
+
+	assert.Equal(t, Errors{
+		{Field: "name", Message: "is required"},
+		{Field: "quantity", Message: "must be at least 0"},
+	}, err)
+}